@@ -8,16 +8,28 @@ import (
 	"time"
 
 	"github.com/eriner/launchpad"
+	"github.com/eriner/launchpad/pkg/color"
 	"github.com/eriner/launchpad/pkg/lpx"
 	"github.com/eriner/launchpad/pkg/middleware"
 )
 
 func main() {
-	// open the launchpad device, in this case a Launchpad X
+	// open the launchpad device: a Launchpad X, Mini MK3 or Pro MK3
 	lp, err := lpx.Open()
 	if err != nil {
 		die(err)
 	}
+	log.Printf("opened %s, firmware version %s", lp.Profile.Name, lpx.VersionString(lp.AppVersion))
+	// the device is reconnected if it is unplugged, and grids are redrawn
+	go func() {
+		for e := range lp.Connection() {
+			if e.Err != nil {
+				log.Printf("%s %s: %v", lp.Profile.Name, e.State, e.Err)
+				continue
+			}
+			log.Printf("%s %s", lp.Profile.Name, e.State)
+		}
+	}()
 	// catch interrupts to exit programmer mode when we ctrl+C
 	ic := make(chan os.Signal, 1)
 	signal.Notify(ic, os.Interrupt, syscall.SIGTERM)
@@ -42,6 +54,11 @@ func main() {
 	if err != nil {
 		die(err)
 	}
+	// errors from the grid, such as failing to write lights to the device or
+	// errors returned by handlers, are sent to the grid's ErrorHandler.
+	testGrid.SetErrorHandler(func(err error) {
+		log.Println(err)
+	})
 	// In theory, we could have mulitple grids or devices. UseGrid activates a grid on
 	// a launchpad.
 	launchpad.UseGrid(lp, testGrid)
@@ -52,26 +69,42 @@ func main() {
 		for y := 1; y < 9; y++ {
 			pad := testGrid.Pad(x, y)
 			// Set all of the lights to Red.
-			pad.Light.RGB(127, 0, 0)
+			pad.UpdateLight(func(l *launchpad.Light) {
+				l.SetRGB(color.Red)
+			})
 
-			// Demonstration of using middleware to wrap a handler for single tap events
-			pad.SingleTapHandler = middleware.SimulatedFeedbackInverted(
-				pad.SingleTapHandler, time.Second*3,
-			)
+			// Demonstration of using middleware to wrap a handler for single tap events.
+			// Because the grid is already running, handlers are replaced with SetHandler.
+			pad.SetHandler(launchpad.SingleTap, middleware.SimulatedFeedbackInverted(
+				pad.Handler(launchpad.SingleTap), time.Second*3,
+			))
 			// And another for double-tap events, but with the logDoubleTap middleware func
-			pad.DoubleTapHandler = logDoubleTap(
+			pad.SetHandler(launchpad.DoubleTap, logDoubleTap(
 				middleware.SimulatedFeedbackPulseToggle(
-					pad.DoubleTapHandler,
+					pad.Handler(launchpad.DoubleTap),
 				),
-			)
+			))
 		}
 	}
 	// here we override the double-tap handler for the bottom left pad.
 	pad := testGrid.Pad(1, 1)
-	pad.DoubleTapHandler = launchpad.HitFunc(func(p *launchpad.Pad) error {
+	pad.SetHandler(launchpad.DoubleTap, launchpad.HitFunc(func(p *launchpad.Pad) error {
 		log.Println("overridden double-tap: no pulsing for this corner!")
 		return nil
-	})
+	}))
+	// pads can also be held down. The HoldTap handler is called once a pad has
+	// been held for the grid's hold threshold.
+	testGrid.SetHoldThreshold(750 * time.Millisecond)
+	pad.SetHandler(launchpad.HoldTap, launchpad.HitFunc(func(p *launchpad.Pad) error {
+		log.Println("held down: corner armed!")
+		return nil
+	}))
+	// the round buttons on the top row and right column are pads too, and have
+	// named helpers.
+	testGrid.TopButton(1).SetHandler(launchpad.SingleTap, launchpad.HitFunc(func(p *launchpad.Pad) error {
+		log.Println("top row button 1 pressed")
+		return nil
+	}))
 	// we can also create our own state-machine (without middleware),
 	// printing the result of taps.
 	taps := testGrid.Taps()
@@ -80,9 +113,11 @@ func main() {
 			tap := <-tapsCh
 			switch tap.Type {
 			case launchpad.SingleTap:
-				log.Println("single tap detected at X: %d, Y: %d", tap.X, tap.Y)
+				log.Printf("single tap detected at X: %d, Y: %d", tap.X, tap.Y)
 			case launchpad.DoubleTap:
-				log.Println("double tap detected at X: %d, Y: %d", tap.X, tap.Y)
+				log.Printf("double tap detected at X: %d, Y: %d", tap.X, tap.Y)
+			case launchpad.HoldTap:
+				log.Printf("hold detected at X: %d, Y: %d", tap.X, tap.Y)
 			}
 		}
 	}(taps)
@@ -99,8 +134,7 @@ func main() {
 func logDoubleTap(next launchpad.HitHandler) launchpad.HitHandler {
 	return launchpad.HitFunc(func(p *launchpad.Pad) error {
 		log.Printf("double tap disco!")
-		next.Apply(p)
-		return nil
+		return next.Apply(p)
 	})
 }
 
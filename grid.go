@@ -1,6 +1,7 @@
 package launchpad
 
 import (
+	"sync"
 	"time"
 )
 
@@ -10,28 +11,48 @@ import (
 func NewGrid(lp Launchpad) (*Grid, error) {
 	g := &Grid{
 		Pads:        make(map[Coordinate]*Pad),
+		lp:          lp,
 		renderDelay: defaultRenderDelay,
+		frameDelay:  defaultRenderDelay,
 		taps:        make(chan Tap, 1024),
 		tapChs:      make([]chan Tap, 0),
+		pressChs:    make([]chan Tap, 0),
+		pressureChs: make([]chan Tap, 0),
 		tapCount:    make(map[Coordinate]int),
 		lastTap:     make(map[Coordinate]time.Time),
 		isDepressed: make(map[Coordinate]bool),
+		pressCount:  make(map[Coordinate]int),
+		held:        make(map[Coordinate]bool),
+		queues:      make(map[Coordinate]*padQueue),
+		done:        make(chan struct{}),
+		dirty:       make(chan struct{}, 1),
+		lastSent:    make(map[Coordinate]Light),
+		redraw:      true,
+
+		doubleTapWindow: defaultDoubleTapWindow,
+		holdThreshold:   defaultHoldThreshold,
 	}
 	for x := 1; x < 10; x++ {
 		for y := 1; y < 10; y++ {
-			// This is our default Pad initializer
 			coord := Coord(x, y)
-			light := &Light{Coord: coord,
-				Effect: EffectStatic,
-			}
-			pad := NewPad()
-			pad.Light = *light
+			pad := defaultPad(coord)
+			pad.onChange = g.markDirty
+			pad.done = g.done
 			g.Pads[coord] = pad
 		}
 	}
 	return g, nil
 }
 
+// defaultPad is our default Pad initializer, used by NewGrid and Clear.
+func defaultPad(c Coordinate) *Pad {
+	pad := NewPad()
+	pad.light = Light{Coord: c,
+		Effect: EffectStatic,
+	}
+	return pad
+}
+
 // Coordinates are a position on the pad
 type Coordinate int64
 
@@ -42,6 +63,42 @@ func (c *Coordinate) XY() (x, y int) {
 	return
 }
 
+// Region returns the part of the device a Coordinate is on
+func (c *Coordinate) Region() Region {
+	x, y := c.XY()
+	switch {
+	case *c != 0 && (x == 0 || y == 0 || y > 9):
+		return RegionExtra
+	case x == 9 && y == 9:
+		return RegionLogo
+	case y == 9:
+		return RegionTop
+	case x == 9:
+		return RegionSide
+	}
+	return RegionGrid
+}
+
+// Region is a part of the device: the 8x8 grid, the round buttons on the
+// top row and right column, or the logo LED.
+type Region int
+
+const (
+	// RegionGrid is the 8x8 grid of pads
+	RegionGrid Region = iota
+	// RegionTop is the row of round buttons above the grid, X 1-8 and Y 9
+	RegionTop
+	// RegionSide is the column of round buttons right of the grid, X 9 and Y 1-8
+	RegionSide
+	// RegionLogo is the logo LED at X 9 and Y 9. It can be lit but not pressed.
+	RegionLogo
+	// RegionExtra are buttons outside the Launchpad X's 9x9, such as the
+	// left column and bottom rows of the Launchpad Pro MK3, at X 0, Y 0
+	// and Y 10. Grids have no pads for them, but they are reported by
+	// Listen.
+	RegionExtra
+)
+
 // Coord converts X and Y coordinates into type Coordinate
 func Coord(x, y int) Coordinate {
 	return Coordinate((y * 10) + x)
@@ -49,17 +106,48 @@ func Coord(x, y int) Coordinate {
 
 // Grid is a state-machine made of Pads that represents  of the desired
 // Pad grid state.
+//
+// Grids are safe for concurrent use. The Pads map itself is never
+// modified after NewGrid.
 type Grid struct {
 	Pads map[Coordinate]*Pad
-	// renderDelay is how long the state machine pauses after
-	// each full-grid redraw.
+	// lp is the device the grid is drawn on, and is blanked on Close.
+	lp Launchpad
+	// renderDelay is the configured minimum time between two frames.
 	renderDelay time.Duration
+	// frameDelay is the current minimum time between two frames. It backs
+	// off from renderDelay while writes fail and recovers as they succeed.
+	frameDelay time.Duration
+	// dirty is signalled when a pad's light changes.
+	dirty chan struct{}
+	// dirtySince is the time of the first change not yet sent to the device.
+	dirtySince time.Time
+	// redraw is set when every pad must be sent in the next frame.
+	redraw bool
+	// lastSent is the last light sent to the device for each coordinate.
+	// It is only used by the render goroutine.
+	lastSent map[Coordinate]Light
+	// overlay holds lights drawn over the pads' own lights, such as the
+	// pixels of ScrollText.
+	overlay map[Coordinate]Light
+	// frameStats describes the last frame sent to the device.
+	frameStats FrameStats
+	// onFrame is called after every frame sent to the device.
+	onFrame func(FrameStats)
+	// renderStats counts the frames and errors of the render loop.
+	renderStats RenderStats
+	// errorHandler receives errors from the grid's goroutines.
+	errorHandler ErrorHandler
 	// taps is fanned out by Taps() and records grid tap events.
 	taps chan Tap
 	// tapChs stores channels that we fan out in Taps()
 	tapChs []chan Tap
+	// pressChs stores channels that we fan out in Presses()
+	pressChs []chan Tap
+	// pressureChs stores channels that we fan out in Pressure()
+	pressureChs []chan Tap
 	// tapCount maintains a record of tap times for coordinates in the
-	// last 200ms
+	// last doubleTapWindow
 	tapCount map[Coordinate]int
 	// lastTap records the last time a coordinate was tapped so we only
 	// ever process the latest tap event.
@@ -67,6 +155,39 @@ type Grid struct {
 	// isDepressed is true if a button is pressed down, false when button
 	// is lifted.
 	isDepressed map[Coordinate]bool
+	// pressCount is incremented on every press of a coordinate, so hold
+	// timers can tell whether the pad has been released since they started.
+	pressCount map[Coordinate]int
+	// held is true once the current press of a coordinate has been
+	// reported as a HoldTap, so its release is not counted as a tap.
+	held map[Coordinate]bool
+
+	// doubleTapWindow is how long we wait after a tap to decide between
+	// a single and double tap.
+	doubleTapWindow time.Duration
+	// holdThreshold is how long a pad must be held to become a HoldTap.
+	holdThreshold time.Duration
+	// holdRepeat is the interval between HoldRepeat taps. Zero disables
+	// HoldRepeat.
+	holdRepeat time.Duration
+	// queues hold the handlers waiting to run on each pad, so they run
+	// in order.
+	queues map[Coordinate]*padQueue
+	// velocityCurve reshapes the velocity of presses before they are
+	// recorded. nil leaves velocities as the device reported them.
+	velocityCurve VelocityCurve
+
+	// mu guards the tap state maps, the tap windows, the subscriber
+	// channels, the render settings and stats, and closed
+	mu sync.Mutex
+	// closed is set once Close has been called. No goroutines may be
+	// started after closed is set.
+	closed bool
+	// done is closed by Close to stop every goroutine started by the grid.
+	done chan struct{}
+	// wg tracks every goroutine started by the grid so Close can wait
+	// for them to exit.
+	wg sync.WaitGroup
 }
 
 // Pad returns a pad for a given set of X and Y coordinates
@@ -74,54 +195,374 @@ func (g *Grid) Pad(x, y int) *Pad {
 	return g.Pads[Coord(x, y)]
 }
 
-// Clear resets all elements in the state machine to their default state
-func (g *Grid) Clear() {
-	//TODO
+// TopButton returns the pad for the i-th round button on the top row,
+// numbered 1-8 from left to right.
+func (g *Grid) TopButton(i int) *Pad {
+	return g.Pad(i, 9)
+}
+
+// SideButton returns the pad for the i-th round button on the right
+// column, numbered 1-8 from bottom to top like the Y axis.
+func (g *Grid) SideButton(i int) *Pad {
+	return g.Pad(9, i)
+}
+
+// Logo returns the pad for the logo LED.
+func (g *Grid) Logo() *Pad {
+	return g.Pad(9, 9)
+}
+
+// SetMaxFrameRate limits how many frames per second are sent to the device.
+// Changes to pads are sent as soon as possible within this limit.
+func (g *Grid) SetMaxFrameRate(fps int) {
+	if fps <= 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.renderDelay = time.Second / time.Duration(fps)
+	g.frameDelay = g.renderDelay
+}
+
+// Redraw sends every pad to the device in the next frame, whether or not
+// it has changed since it was last sent.
+func (g *Grid) Redraw() {
+	g.mu.Lock()
+	g.redraw = true
+	g.mu.Unlock()
+	g.markDirty()
+}
+
+// FrameStats returns statistics about the last frame sent to the device.
+func (g *Grid) FrameStats() FrameStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.frameStats
+}
+
+// OnFrame sets a function called with the statistics of every frame sent
+// to the device. f is called from the render goroutine and should not block.
+func (g *Grid) OnFrame(f func(FrameStats)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onFrame = f
+}
+
+// RenderStats returns the frame and error counts of the render loop.
+func (g *Grid) RenderStats() RenderStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := g.renderStats
+	stats.Delay = g.frameDelay
+	return stats
+}
+
+// SetErrorHandler sets the function which receives errors from the grid:
+// a *RenderError when a frame cannot be written to the device, or a
+// *HandlerError when a HitHandler returns an error or panics.
+// h is called from the grid's goroutines and should not block.
+// Errors are dropped if no ErrorHandler is set.
+func (g *Grid) SetErrorHandler(h ErrorHandler) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.errorHandler = h
+}
+
+// reportError sends err to the grid's ErrorHandler
+func (g *Grid) reportError(err error) {
+	g.mu.Lock()
+	h := g.errorHandler
+	g.mu.Unlock()
+	if h != nil {
+		h(err)
+	}
+}
+
+// RenderStats describes the health of a grid's render loop.
+type RenderStats struct {
+	// Frames is the number of frames written successfully
+	Frames int
+	// Errors is the number of frames which failed to write
+	Errors int
+	// LastError is the most recent write error, and LastErrorTime when
+	// it happened.
+	LastError     error
+	LastErrorTime time.Time
+	// Delay is the current minimum time between frames, which grows
+	// while writes are failing.
+	Delay time.Duration
+}
+
+// FrameStats describes a frame sent to the device by the render loop.
+type FrameStats struct {
+	// Time is when the frame finished writing
+	Time time.Time
+	// Pads is the number of pads sent in the frame
+	Pads int
+	// Bytes is the number of bytes written, if the device is a FrameSizer
+	Bytes int
+	// Latency is the time between the first change in the frame and the
+	// frame finishing writing.
+	Latency time.Duration
+}
+
+// markDirty schedules a frame with the render loop.
+func (g *Grid) markDirty() {
+	g.mu.Lock()
+	if g.dirtySince.IsZero() {
+		g.dirtySince = time.Now()
+	}
+	g.mu.Unlock()
+	select {
+	case g.dirty <- struct{}{}:
+	default:
+	}
+}
+
+// setOverlay replaces the lights drawn over the pads' own lights. The map
+// is never modified once set, so render may read it without holding mu.
+func (g *Grid) setOverlay(overlay map[Coordinate]Light) {
+	g.mu.Lock()
+	g.overlay = overlay
+	g.mu.Unlock()
+	g.markDirty()
+}
+
+// SetDoubleTapWindow sets how long the grid waits after a tap for a
+// second tap. Taps are reported this long after the pad is released.
+func (g *Grid) SetDoubleTapWindow(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.doubleTapWindow = d
+}
+
+// SetHoldThreshold sets how long a pad must be held down before a
+// HoldTap is reported.
+func (g *Grid) SetHoldThreshold(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.holdThreshold = d
+}
+
+// SetHoldRepeat sets the interval between HoldRepeat taps while a pad
+// stays held after its HoldTap. Zero, the default, disables HoldRepeat.
+func (g *Grid) SetHoldRepeat(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.holdRepeat = d
+}
+
+// SetVelocityCurve sets the curve applied to the velocity of every press
+// before it reaches pads, handlers and subscribers. A nil curve, the
+// default, leaves velocities as the device reported them.
+func (g *Grid) SetVelocityCurve(curve VelocityCurve) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.velocityCurve = curve
+}
 
+// Clear resets every Pad's Light and handlers to their NewGrid defaults.
+func (g *Grid) Clear() {
+	for coord, pad := range g.Pads {
+		d := defaultPad(coord)
+		pad.SetLight(d.Light())
+		for _, t := range []TapType{SingleTap, DoubleTap, HoldTap, Press, Release, Aftertouch} {
+			pad.SetHandler(t, d.Handler(t))
+		}
+	}
 }
 
+// Close stops the goroutines started by UseGrid and Taps, closes every
+// channel returned by Taps and blanks the device. Close returns once all
+// of the grid's goroutines, including running HitHandlers, have exited.
+// Handlers still queued are not run, and handlers which wait should stop
+// when their Pad's Done channel is closed.
+//
+// Close must not be called from a HitHandler, as it would wait for the
+// handler to return. A handler which closes the grid should do so in a
+// new goroutine, with go g.Close().
 func (g *Grid) Close() error {
-	//TODO: when a grid is closed, we should Clear()
-	return nil
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil
+	}
+	g.closed = true
+	close(g.done)
+	g.mu.Unlock()
+
+	g.wg.Wait()
+
+	g.mu.Lock()
+	for _, subs := range []*[]chan Tap{&g.tapChs, &g.pressChs, &g.pressureChs} {
+		for _, ch := range *subs {
+			close(ch)
+		}
+		*subs = nil
+	}
+	lp := g.lp
+	g.mu.Unlock()
+	if lp == nil {
+		return nil
+	}
+	return lp.Clear()
+}
+
+// goFunc runs f in a goroutine tracked by the grid. It returns false
+// without running f if the grid has been closed.
+func (g *Grid) goFunc(f func()) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return false
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		f()
+	}()
+	return true
 }
 
 // Taps returns a channel of tap events associated with a grid.
+// The channel is closed when the grid is closed. Taps are dropped if the
+// channel is not read and its buffer fills.
 func (g *Grid) Taps() chan Tap {
-	tapCh := make(chan Tap)
-	if len(g.tapChs) != 0 {
-		g.tapChs = append(g.tapChs, tapCh)
+	tapCh := make(chan Tap, subscriberBuffer)
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		close(tapCh)
+		return tapCh
+	}
+	g.tapChs = append(g.tapChs, tapCh)
+	first := len(g.tapChs) == 1
+	g.mu.Unlock()
+	if !first {
 		return tapCh
 	}
 	// The code below here is the setup for the Taps() function
 	// and will be executed on first invocation of Taps()
-	g.tapChs = append(g.tapChs, tapCh)
-	go func(gg *Grid) {
+	g.goFunc(func() {
 		for {
-			tap := <-gg.taps
-			go func(grid *Grid, t Tap) {
-				// 200ms is the time we wait to determine if this was a
-				// single or double tap
-				time.Sleep(200 * time.Millisecond)
-				//log.Println(t.HoldDuration)
-				t.DecisionTime = time.Now()
-				switch tc := g.tapCount[t.Coordinate]; tc {
-				case 0: //NOTE: this often occurs after double taps
-					return
-				case 1:
-					t.Type = SingleTap
-					g.tapCount[t.Coordinate] = 0
-				case 2:
-					t.Type = DoubleTap
-					g.tapCount[t.Coordinate] = 0
-
-				}
-				for _, tapCh := range grid.tapChs {
-					tapCh <- t
-				}
-
-			}(gg, tap)
+			var tap Tap
+			select {
+			case tap = <-g.taps:
+			case <-g.done:
+				return
+			}
+			g.goFunc(func() {
+				g.classify(tap)
+			})
 		}
-
-	}(g)
+	})
 	return tapCh
 }
+
+// classify decides whether a tap was a single or double tap and fans
+// the result out to every channel returned by Taps.
+func (g *Grid) classify(t Tap) {
+	g.mu.Lock()
+	window := g.doubleTapWindow
+	g.mu.Unlock()
+	// we wait for the double tap window to determine if this was a
+	// single or double tap
+	select {
+	case <-time.After(window):
+	case <-g.done:
+		return
+	}
+	t.DecisionTime = time.Now()
+	g.mu.Lock()
+	switch tc := g.tapCount[t.Coordinate]; tc {
+	case 0: //NOTE: this often occurs after double taps
+		g.mu.Unlock()
+		return
+	case 1:
+		t.Type = SingleTap
+		g.tapCount[t.Coordinate] = 0
+	default:
+		// three or more taps inside the window are still a double tap
+		t.Type = DoubleTap
+		g.tapCount[t.Coordinate] = 0
+	}
+	g.mu.Unlock()
+	g.broadcast(&g.tapChs, t)
+}
+
+// hold waits for a press to pass the hold threshold, then reports a
+// HoldTap followed by HoldRepeat taps until the pad is released.
+// press is the pressCount of the press being watched.
+func (g *Grid) hold(t Tap, press int) {
+	g.mu.Lock()
+	wait := g.holdThreshold
+	g.mu.Unlock()
+	t.Type = HoldTap
+	for {
+		select {
+		case <-time.After(wait):
+		case <-g.done:
+			return
+		}
+		g.mu.Lock()
+		if !g.isDepressed[t.Coordinate] || g.pressCount[t.Coordinate] != press {
+			g.mu.Unlock()
+			return
+		}
+		g.held[t.Coordinate] = true
+		wait = g.holdRepeat
+		g.mu.Unlock()
+		t.DecisionTime = time.Now()
+		t.HoldDuration = t.DecisionTime.Sub(t.Time)
+		g.broadcast(&g.tapChs, t)
+		if wait <= 0 {
+			return
+		}
+		t.Type = HoldRepeat
+	}
+}
+
+// Presses returns a channel of Press and Release taps, which are sent as
+// soon as the device reports them, without waiting for the tap to be
+// classified. The channel is closed when the grid is closed. Like Taps,
+// events are dropped if the channel is not read and its buffer fills.
+func (g *Grid) Presses() chan Tap {
+	return g.subscribe(&g.pressChs)
+}
+
+// Pressure returns a channel of Aftertouch taps carrying the pressure
+// applied to held pads. Channel aftertouch, which is not tied to a pad,
+// is sent with a zero Coordinate. The channel is closed when the grid is
+// closed.
+func (g *Grid) Pressure() chan Tap {
+	return g.subscribe(&g.pressureChs)
+}
+
+// subscribe adds a new channel to subs, which is one of pressChs or
+// pressureChs.
+func (g *Grid) subscribe(subs *[]chan Tap) chan Tap {
+	ch := make(chan Tap, subscriberBuffer)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		close(ch)
+		return ch
+	}
+	*subs = append(*subs, ch)
+	return ch
+}
+
+// broadcast fans a tap out to every channel in subs, which is one of
+// tapChs, pressChs or pressureChs. Taps are dropped for subscribers which
+// have fallen subscriberBuffer taps behind, so one slow subscriber cannot
+// hold up the grid.
+func (g *Grid) broadcast(subs *[]chan Tap, t Tap) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, ch := range *subs {
+		select {
+		case ch <- t:
+		default:
+		}
+	}
+}
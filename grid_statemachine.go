@@ -1,78 +1,348 @@
 package launchpad
 
 import (
-	"log"
+	"sort"
 	"time"
 )
 
-// UseGrid launches the a grid's state machine on a given Launchpad
+// UseGrid launches the a grid's state machine on a given Launchpad.
+// The state machine runs until the grid is closed.
 func UseGrid(lp Launchpad, g *Grid) {
+	g.mu.Lock()
+	g.lp = lp
+	g.mu.Unlock()
 	// start a listener for taps, recording the tap time.
-	go func(p Launchpad, g *Grid) {
-		c := p.Listen()
+	g.goFunc(func() {
+		c := lp.Listen()
 		for {
-			tap := <-c
+			var tap Tap
+			select {
+			case tap = <-c:
+			case <-g.done:
+				return
+			}
 			tap.Time = time.Now()
-			g.isDepressed[tap.Coordinate] = !g.isDepressed[tap.Coordinate]
-			// when button has lifted after a press
-			if !g.isDepressed[tap.Coordinate] {
-				//BUGFIX: sometimes isDepressed becomes inverted from the actual pad state,
-				// meaning the HoldDuration becomes time.Now().Sub(g.lastTap[tap.Coordinate])
-				// I'm not quite sure why this happens, or if this is just a normal desync
-				// of the Launchpad.
-				// To handle this, we invert it here if we detect this.
-				// button presses run on a 200ms clock.
-				holdDuration := tap.Time.Sub(g.lastTap[tap.Coordinate])
-				if holdDuration > 200*time.Millisecond {
-					//NOTE: "desyncs" here are at least sometimes just overruns of our detection
-					// window, meaning this conditional may useful if we ever create a HoldTap
-					// tap type.
-					//log.Println("fixed desync")
-					g.isDepressed[tap.Coordinate] = !g.isDepressed[tap.Coordinate]
-					g.lastTap[tap.Coordinate] = time.Now()
-					continue
-				} else {
-					tap.HoldDuration = holdDuration
-					g.tapCount[tap.Coordinate]++
-					g.taps <- tap
-				}
+			if tap.Type == Aftertouch {
+				g.aftertouch(tap)
+				continue
+			}
+			tapped, press := g.press(&tap)
+			if press != 0 {
+				tap.Velocity = g.velocity(tap.Velocity)
+			}
+			// raw press and release events are sent right away
+			pressTap := tap
+			if press != 0 {
+				pressTap.Type = Press
+			} else {
+				pressTap.Type = Release
+			}
+			if pad, ok := g.Pads[tap.Coordinate]; ok {
+				pad.touch(pressTap)
+			}
+			g.dispatch(tap.Coordinate, pressTap.Type)
+			g.broadcast(&g.pressChs, pressTap)
+			if press != 0 {
+				t := tap
+				g.goFunc(func() {
+					g.hold(t, press)
+				})
+			}
+			if !tapped {
+				continue
+			}
+			select {
+			case g.taps <- tap:
+			case <-g.done:
+				return
 			}
-			g.lastTap[tap.Coordinate] = tap.Time
 		}
-	}(lp, g)
-	// build and apply desired grid state
-	go func(p Launchpad, g *Grid) {
+	})
+	// build and apply desired grid state, sending only pads that changed
+	g.Redraw()
+	g.goFunc(func() {
+		var last time.Time
 		for {
-			var lights []Light
-			for _, pad := range g.Pads {
-				if pad.Light.DisplayLocked {
-					continue
+			select {
+			case <-g.dirty:
+			case <-g.done:
+				return
+			}
+			g.mu.Lock()
+			wait := g.frameDelay - time.Since(last)
+			g.mu.Unlock()
+			if wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-g.done:
+					return
 				}
-				lights = append(lights, pad.Light)
 			}
-			if err := p.LightSysEx(lights); err != nil {
-				//TODO: gather MIDI error count and expose in
-				// grid so that we can increase the renderDelay
-				log.Println(err)
+			if rc, ok := lp.(Reconnecter); ok && !rc.Connected() {
+				// the device is redrawn when it is reconnected
+				continue
+			}
+			if err := g.render(lp); err != nil {
+				g.reportError(err)
 			}
-			time.Sleep(g.renderDelay)
+			last = time.Now()
 		}
-	}(lp, g)
-	go func(g *Grid) {
-		tapsCh := g.Taps()
-		for {
-			tap := <-tapsCh
-			pad := g.Pads[tap.Coordinate]
-			go func(p *Pad, t Tap) {
-				//TODO: handle errors below
-				switch t.Type {
-				case SingleTap:
-					p.SingleTapHandler.Apply(p)
-				case DoubleTap:
-					p.DoubleTapHandler.Apply(p)
+	})
+	// a reconnected device has lost its lights, so every pad is resent
+	if rc, ok := lp.(Reconnecter); ok {
+		reconnected := rc.Reconnected()
+		g.goFunc(func() {
+			for {
+				select {
+				case _, ok := <-reconnected:
+					if !ok {
+						return
+					}
+					g.Redraw()
+				case <-g.done:
+					return
 				}
-			}(pad, tap)
+			}
+		})
+	}
+	tapsCh := g.Taps()
+	g.goFunc(func() {
+		for {
+			var t Tap
+			select {
+			case t = <-tapsCh:
+			case <-g.done:
+				return
+			}
+			g.dispatch(t.Coordinate, t.Type)
 		}
-	}(g)
+	})
 	return
 }
+
+// press records a pad event in the grid's state. It returns true if the
+// event completed a tap which should be classified. When the event is a
+// press, its pressCount is returned so the caller can watch for a hold.
+func (g *Grid) press(tap *Tap) (tapped bool, press int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch tap.Type {
+	case Press:
+		g.isDepressed[tap.Coordinate] = true
+	case Release:
+		g.isDepressed[tap.Coordinate] = false
+	default:
+		// devices which do not report Press and Release are toggled
+		g.isDepressed[tap.Coordinate] = !g.isDepressed[tap.Coordinate]
+	}
+	if g.isDepressed[tap.Coordinate] {
+		g.pressCount[tap.Coordinate]++
+		g.held[tap.Coordinate] = false
+		g.lastTap[tap.Coordinate] = tap.Time
+		return false, g.pressCount[tap.Coordinate]
+	}
+	// when button has lifted after a press
+	//NOTE: presses longer than the double tap window used to be treated as
+	// a desync of isDepressed and thrown away. They are now either taps or,
+	// past the hold threshold, HoldTaps which have already been reported.
+	// Desyncs can still happen with devices that only toggle isDepressed.
+	tap.HoldDuration = tap.Time.Sub(g.lastTap[tap.Coordinate])
+	g.lastTap[tap.Coordinate] = tap.Time
+	if g.held[tap.Coordinate] {
+		g.held[tap.Coordinate] = false
+		return false, 0
+	}
+	g.tapCount[tap.Coordinate]++
+	return true, 0
+}
+
+// velocity applies the grid's velocity curve to the velocity of a press.
+func (g *Grid) velocity(v int) int {
+	g.mu.Lock()
+	curve := g.velocityCurve
+	g.mu.Unlock()
+	if curve == nil {
+		return v
+	}
+	v = curve(v)
+	// a press always has a velocity, 0 would be read as a release
+	if v < 1 {
+		v = 1
+	}
+	if v > 127 {
+		v = 127
+	}
+	return v
+}
+
+// aftertouch records the pressure of an Aftertouch tap on its pad, or on
+// every held pad for channel aftertouch, and sends it to Pressure().
+func (g *Grid) aftertouch(t Tap) {
+	var coords []Coordinate
+	g.mu.Lock()
+	if t.Coordinate == 0 {
+		for c, down := range g.isDepressed {
+			if down {
+				coords = append(coords, c)
+			}
+		}
+	} else {
+		coords = append(coords, t.Coordinate)
+	}
+	g.mu.Unlock()
+	for _, c := range coords {
+		if pad, ok := g.Pads[c]; ok {
+			pad.touch(t)
+			g.dispatch(c, Aftertouch)
+		}
+	}
+	g.broadcast(&g.pressureChs, t)
+}
+
+// render sends every pad which changed since the last frame to the device.
+// render is only called from the render goroutine.
+func (g *Grid) render(lp Launchpad) error {
+	g.mu.Lock()
+	since := g.dirtySince
+	g.dirtySince = time.Time{}
+	if g.redraw {
+		g.lastSent = make(map[Coordinate]Light)
+		g.redraw = false
+	}
+	overlay := g.overlay
+	g.mu.Unlock()
+	var lights []Light
+	for coord, pad := range g.Pads {
+		light := pad.Light()
+		if light.DisplayLocked {
+			continue
+		}
+		if over, ok := overlay[coord]; ok {
+			light = over
+		}
+		if sent, ok := g.lastSent[coord]; ok && sent == light {
+			continue
+		}
+		lights = append(lights, light)
+	}
+	if len(lights) == 0 {
+		return nil
+	}
+	sort.Slice(lights, func(i, j int) bool {
+		return lights[i].Coord < lights[j].Coord
+	})
+	if err := lp.LightSysEx(lights); err != nil {
+		// back off and try again on the next frame
+		g.mu.Lock()
+		if !since.IsZero() && (g.dirtySince.IsZero() || since.Before(g.dirtySince)) {
+			g.dirtySince = since
+		}
+		g.renderStats.Errors++
+		g.renderStats.LastError = err
+		g.renderStats.LastErrorTime = time.Now()
+		g.frameDelay *= 2
+		if g.frameDelay > maxRenderDelay {
+			g.frameDelay = maxRenderDelay
+		}
+		g.mu.Unlock()
+		g.markDirty()
+		return &RenderError{Pads: len(lights), Err: err}
+	}
+	for _, light := range lights {
+		g.lastSent[light.Coord] = light
+	}
+	stats := FrameStats{
+		Time: time.Now(),
+		Pads: len(lights),
+	}
+	if fs, ok := lp.(FrameSizer); ok {
+		stats.Bytes = fs.FrameSize(lights)
+	}
+	if !since.IsZero() {
+		stats.Latency = stats.Time.Sub(since)
+	}
+	g.mu.Lock()
+	g.frameStats = stats
+	g.renderStats.Frames++
+	// recover from any back off
+	if g.frameDelay > g.renderDelay {
+		g.frameDelay /= 2
+		if g.frameDelay < g.renderDelay {
+			g.frameDelay = g.renderDelay
+		}
+	}
+	onFrame := g.onFrame
+	g.mu.Unlock()
+	if onFrame != nil {
+		onFrame(stats)
+	}
+	return nil
+}
+
+// dispatch queues the handler for a TapType on the pad at c. Each pad's
+// handlers run one at a time in a goroutine of their own, in the order
+// their taps arrived, sending any error or recovered panic to the
+// ErrorHandler.
+func (g *Grid) dispatch(c Coordinate, t TapType) {
+	pad, ok := g.Pads[c]
+	if !ok {
+		return
+	}
+	g.mu.Lock()
+	q := g.queues[c]
+	if q == nil {
+		q = &padQueue{}
+		g.queues[c] = q
+	}
+	if n := len(q.types); t == Aftertouch && n > 0 && q.types[n-1] == Aftertouch {
+		// The Aftertouch handler reads the latest pressure from the pad, so one
+		// waiting Aftertouch is enough
+		g.mu.Unlock()
+		return
+	}
+	q.types = append(q.types, t)
+	if q.running {
+		g.mu.Unlock()
+		return
+	}
+	q.running = true
+	g.mu.Unlock()
+	if !g.goFunc(func() { g.drain(c, pad, q) }) {
+		g.mu.Lock()
+		q.running = false
+		q.types = nil
+		g.mu.Unlock()
+	}
+}
+
+// padQueue holds the TapTypes waiting for their handlers on one pad.
+// running is true while a goroutine is draining the queue. Both are
+// guarded by the grid's mu.
+type padQueue struct {
+	types   []TapType
+	running bool
+}
+
+// drain runs the handlers queued on a pad until the queue is empty or the
+// grid is closed.
+func (g *Grid) drain(c Coordinate, pad *Pad, q *padQueue) {
+	for {
+		g.mu.Lock()
+		if len(q.types) == 0 || g.closed {
+			q.running = false
+			q.types = nil
+			g.mu.Unlock()
+			return
+		}
+		t := q.types[0]
+		q.types = q.types[1:]
+		g.mu.Unlock()
+		if err := pad.apply(t); err != nil {
+			g.reportError(&HandlerError{
+				Coordinate: c,
+				Type:       t,
+				Err:        err,
+			})
+		}
+	}
+}
@@ -0,0 +1,595 @@
+package launchpad_test
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eriner/launchpad"
+	"github.com/eriner/launchpad/pkg/fake"
+)
+
+// testWindow is the double tap window of test grids, kept short so the
+// tests run quickly.
+const testWindow = 50 * time.Millisecond
+
+// newTestGrid returns a grid running on a fake device. The grid is closed
+// when the test ends.
+func newTestGrid(t *testing.T) (*launchpad.Grid, *fake.Launchpad) {
+	t.Helper()
+	dev := fake.New()
+	g, err := launchpad.NewGrid(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetDoubleTapWindow(testWindow)
+	g.SetMaxFrameRate(1000)
+	t.Cleanup(func() {
+		g.Close()
+	})
+	launchpad.UseGrid(dev, g)
+	return g, dev
+}
+
+// nextTap returns the next tap from ch, failing the test if none arrives.
+func nextTap(t *testing.T, ch chan launchpad.Tap) launchpad.Tap {
+	t.Helper()
+	select {
+	case tap := <-ch:
+		return tap
+	case <-time.After(time.Second):
+		t.Fatal("no tap was received")
+	}
+	return launchpad.Tap{}
+}
+
+// noTap fails the test if a tap arrives on ch within a few tap windows.
+func noTap(t *testing.T, ch chan launchpad.Tap) {
+	t.Helper()
+	select {
+	case tap := <-ch:
+		t.Fatalf("unexpected %s at X: %d, Y: %d", tap.Type, tap.X, tap.Y)
+	case <-time.After(4 * testWindow):
+	}
+}
+
+// waitFor polls cond until it is true, failing the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGridPresses(t *testing.T) {
+	g, dev := newTestGrid(t)
+	presses := g.Presses()
+	dev.PressVelocity(3, 4, 90)
+	tap := nextTap(t, presses)
+	if tap.Type != launchpad.Press || tap.X != 3 || tap.Y != 4 || tap.Velocity != 90 {
+		t.Fatalf("got %+v, want a press at X: 3, Y: 4 with velocity 90", tap)
+	}
+	if v := g.Pad(3, 4).Velocity(); v != 90 {
+		t.Fatalf("pad velocity is %d, want 90", v)
+	}
+	dev.Release(3, 4)
+	if tap := nextTap(t, presses); tap.Type != launchpad.Release || tap.Coordinate != launchpad.Coord(3, 4) {
+		t.Fatalf("got %+v, want a release at X: 3, Y: 4", tap)
+	}
+}
+
+func TestGridTapClassification(t *testing.T) {
+	tests := []struct {
+		name string
+		taps int
+		want launchpad.TapType
+	}{
+		{"single", 1, launchpad.SingleTap},
+		{"double", 2, launchpad.DoubleTap},
+		{"triple", 3, launchpad.DoubleTap},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, dev := newTestGrid(t)
+			taps := g.Taps()
+			for i := 0; i < tt.taps; i++ {
+				dev.Tap(2, 3, time.Millisecond)
+			}
+			tap := nextTap(t, taps)
+			if tap.Type != tt.want || tap.X != 2 || tap.Y != 3 {
+				t.Fatalf("got %s at X: %d, Y: %d, want %s at X: 2, Y: 3", tap.Type, tap.X, tap.Y, tt.want)
+			}
+			noTap(t, taps)
+			// the tap count is reset, so the next tap stands alone
+			dev.Tap(2, 3, time.Millisecond)
+			if tap := nextTap(t, taps); tap.Type != launchpad.SingleTap {
+				t.Fatalf("got %s after the %s, want %s", tap.Type, tt.want, launchpad.SingleTap)
+			}
+		})
+	}
+}
+
+func TestGridTapHandlers(t *testing.T) {
+	g, dev := newTestGrid(t)
+	got := make(chan launchpad.TapType, 4)
+	for _, tt := range []launchpad.TapType{launchpad.SingleTap, launchpad.DoubleTap} {
+		tt := tt
+		g.Pad(4, 4).SetHandler(tt, launchpad.HitFunc(func(p *launchpad.Pad) error {
+			got <- tt
+			return nil
+		}))
+	}
+	dev.Tap(4, 4, time.Millisecond)
+	dev.Tap(4, 4, time.Millisecond)
+	select {
+	case tt := <-got:
+		if tt != launchpad.DoubleTap {
+			t.Fatalf("got %s handler, want %s", tt, launchpad.DoubleTap)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no handler was run")
+	}
+	select {
+	case tt := <-got:
+		t.Fatalf("unexpected %s handler", tt)
+	case <-time.After(4 * testWindow):
+	}
+}
+
+func TestGridHold(t *testing.T) {
+	const threshold, repeat = 50 * time.Millisecond, 20 * time.Millisecond
+	g, dev := newTestGrid(t)
+	g.SetHoldThreshold(threshold)
+	g.SetHoldRepeat(repeat)
+	// the hold handler runs for HoldTap and every HoldRepeat
+	var held int32
+	g.Pad(2, 2).SetHandler(launchpad.HoldTap, launchpad.HitFunc(func(p *launchpad.Pad) error {
+		atomic.AddInt32(&held, 1)
+		return nil
+	}))
+	taps := g.Taps()
+	dev.Press(2, 2)
+	tap := nextTap(t, taps)
+	if tap.Type != launchpad.HoldTap || tap.Coordinate != launchpad.Coord(2, 2) {
+		t.Fatalf("got %s at X: %d, Y: %d, want %s at X: 2, Y: 2", tap.Type, tap.X, tap.Y, launchpad.HoldTap)
+	}
+	if tap.HoldDuration < threshold {
+		t.Fatalf("%s after %v, want at least %v", tap.Type, tap.HoldDuration, threshold)
+	}
+	// HoldRepeat follows every repeat interval while the pad stays held
+	last := tap.HoldDuration
+	for i := 0; i < 3; i++ {
+		tap := nextTap(t, taps)
+		if tap.Type != launchpad.HoldRepeat {
+			t.Fatalf("got %s, want %s", tap.Type, launchpad.HoldRepeat)
+		}
+		if tap.HoldDuration < last+repeat {
+			t.Fatalf("%s after %v, want at least %v", tap.Type, tap.HoldDuration, last+repeat)
+		}
+		last = tap.HoldDuration
+	}
+	// the release after a hold is not also a tap
+	dev.Release(2, 2)
+	timeout := time.After(4 * testWindow)
+	for done := false; !done; {
+		select {
+		case tap := <-taps:
+			// a repeat may already be on its way
+			if tap.Type != launchpad.HoldRepeat {
+				t.Fatalf("unexpected %s after the release", tap.Type)
+			}
+		case <-timeout:
+			done = true
+		}
+	}
+	n := atomic.LoadInt32(&held)
+	if n < 4 {
+		t.Fatalf("hold handler ran %d times, want at least 4", n)
+	}
+	noTap(t, taps)
+	if m := atomic.LoadInt32(&held); m != n {
+		t.Fatalf("hold handler ran %d more times after the release", m-n)
+	}
+	// the next short press is a tap again
+	dev.Tap(2, 2, time.Millisecond)
+	if tap := nextTap(t, taps); tap.Type != launchpad.SingleTap {
+		t.Fatalf("got %s after the hold, want %s", tap.Type, launchpad.SingleTap)
+	}
+}
+
+func TestGridHoldThreshold(t *testing.T) {
+	g, dev := newTestGrid(t)
+	g.SetHoldThreshold(100 * time.Millisecond)
+	taps := g.Taps()
+	// a press shorter than the threshold is only a tap
+	dev.Tap(3, 3, 20*time.Millisecond)
+	if tap := nextTap(t, taps); tap.Type != launchpad.SingleTap {
+		t.Fatalf("got %s, want %s", tap.Type, launchpad.SingleTap)
+	}
+	noTap(t, taps)
+	// without a repeat interval, a hold is reported once
+	dev.Press(3, 3)
+	if tap := nextTap(t, taps); tap.Type != launchpad.HoldTap {
+		t.Fatalf("got %s, want %s", tap.Type, launchpad.HoldTap)
+	}
+	noTap(t, taps)
+	dev.Release(3, 3)
+	noTap(t, taps)
+}
+
+func TestGridHandlerErrors(t *testing.T) {
+	failed := errors.New("handler failed")
+	tests := []struct {
+		name    string
+		typ     launchpad.TapType
+		handler launchpad.HitFunc
+		check   func(*testing.T, error)
+	}{
+		{
+			name: "error",
+			typ:  launchpad.Press,
+			handler: func(p *launchpad.Pad) error {
+				return failed
+			},
+			check: func(t *testing.T, err error) {
+				if !errors.Is(err, failed) {
+					t.Fatalf("got %v, want it to wrap %v", err, failed)
+				}
+				want := "launchpad: press handler at X: 3, Y: 4: handler failed"
+				if err.Error() != want {
+					t.Fatalf("got %q, want %q", err.Error(), want)
+				}
+			},
+		},
+		{
+			name: "panic",
+			typ:  launchpad.Release,
+			handler: func(p *launchpad.Pad) error {
+				panic("boom")
+			},
+			check: func(t *testing.T, err error) {
+				var pe *launchpad.PanicError
+				if !errors.As(err, &pe) {
+					t.Fatalf("got %v, want a PanicError", err)
+				}
+				if pe.Value != "boom" || len(pe.Stack) == 0 {
+					t.Fatalf("recovered %v with a %d byte stack", pe.Value, len(pe.Stack))
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, dev := newTestGrid(t)
+			errs := make(chan error, 4)
+			g.SetErrorHandler(func(err error) {
+				errs <- err
+			})
+			g.Pad(3, 4).SetHandler(tt.typ, tt.handler)
+			// the pad keeps running its handlers after each failure
+			for i := 0; i < 2; i++ {
+				dev.Press(3, 4)
+				dev.Release(3, 4)
+				select {
+				case err := <-errs:
+					var he *launchpad.HandlerError
+					if !errors.As(err, &he) {
+						t.Fatalf("got %v, want a HandlerError", err)
+					}
+					if he.Coordinate != launchpad.Coord(3, 4) || he.Type != tt.typ {
+						t.Fatalf("error from %s at %v, want %s at X: 3, Y: 4", he.Type, he.Coordinate, tt.typ)
+					}
+					tt.check(t, err)
+				case <-time.After(time.Second):
+					t.Fatal("no error was reported")
+				}
+			}
+			// the pad's other handlers succeed and report nothing
+			select {
+			case err := <-errs:
+				t.Fatalf("unexpected error %v", err)
+			case <-time.After(4 * testWindow):
+			}
+		})
+	}
+}
+
+func TestGridLightRace(t *testing.T) {
+	g, dev := newTestGrid(t)
+	var wg sync.WaitGroup
+	for x := 1; x < 10; x++ {
+		wg.Add(1)
+		go func(x int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				y := 1 + i%9
+				if i%2 == 0 {
+					g.Pad(x, y).SetLight(launchpad.Light{
+						Coord:  launchpad.Coord(x, y),
+						Effect: launchpad.EffectStatic,
+						R:      int8(i),
+					})
+				} else {
+					g.Pad(x, y).UpdateLight(func(l *launchpad.Light) {
+						l.R = int8(i)
+						l.Pulse()
+					})
+				}
+				g.Pad(x, y).Light()
+				if i%10 == 0 {
+					g.Redraw()
+				}
+			}
+		}(x)
+	}
+	wg.Wait()
+	// every pad's last change reaches the device
+	deadline := time.Now().Add(time.Second)
+	for x := 1; x < 10; x++ {
+		for y := 1; y < 10; y++ {
+			want := g.Pad(x, y).Light()
+			for {
+				lit, _ := dev.Lit(launchpad.Coord(x, y))
+				if lit.R == want.R && lit.Effect == want.Effect {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("X: %d, Y: %d is lit %+v, want %+v", x, y, lit, want)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+}
+
+func TestGridRenderChanges(t *testing.T) {
+	g, dev := newTestGrid(t)
+	// the first frame sends every pad
+	waitFor(t, "the first frame", func() bool {
+		return len(dev.Frames()) == 1
+	})
+	first := dev.Frames()[0]
+	if !first.SysEx || len(first.Lights) != 81 {
+		t.Fatalf("first frame has %d lights, SysEx %v, want 81 by SysEx", len(first.Lights), first.SysEx)
+	}
+
+	// later frames only send the pads which changed
+	g.Pad(2, 7).UpdateLight(func(l *launchpad.Light) {
+		l.RGB(127, 0, 0)
+		l.Pulse()
+	})
+	waitFor(t, "the second frame", func() bool {
+		return len(dev.Frames()) == 2
+	})
+	frame := dev.Frames()[1]
+	if len(frame.Lights) != 1 || frame.Lights[0].Coord != launchpad.Coord(2, 7) {
+		t.Fatalf("second frame is %+v, want only X: 2, Y: 7", frame.Lights)
+	}
+	if lit, _ := dev.Lit(launchpad.Coord(2, 7)); lit != g.Pad(2, 7).Light() {
+		t.Fatalf("X: 2, Y: 7 is lit %+v, want %+v", lit, g.Pad(2, 7).Light())
+	}
+	g.Pad(2, 7).UpdateLight(func(l *launchpad.Light) {
+		l.Off()
+	})
+	waitFor(t, "the pad to turn off", func() bool {
+		return !dev.On(launchpad.Coord(2, 7))
+	})
+}
+
+func TestGridRenderBackoff(t *testing.T) {
+	g, dev := newTestGrid(t)
+	errs := make(chan error, 64)
+	g.SetErrorHandler(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	waitFor(t, "the first frame", func() bool {
+		return g.RenderStats().Frames == 1
+	})
+	base := g.RenderStats().Delay
+	failed := errors.New("write failed")
+	dev.FailWrites(failed)
+	g.Pad(1, 1).SetLight(launchpad.Light{Coord: launchpad.Coord(1, 1), Effect: launchpad.EffectStatic, Color: 5})
+
+	select {
+	case err := <-errs:
+		var re *launchpad.RenderError
+		if !errors.As(err, &re) || re.Pads != 1 || !errors.Is(err, failed) {
+			t.Fatalf("got %v, want a RenderError of 1 pad wrapping %v", err, failed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no RenderError was reported")
+	}
+	// failed frames are retried, each time a little later
+	waitFor(t, "two failed frames", func() bool {
+		return g.RenderStats().Errors >= 2
+	})
+	early := g.RenderStats()
+	waitFor(t, "five failed frames", func() bool {
+		return g.RenderStats().Errors >= 5
+	})
+	late := g.RenderStats()
+	if early.Delay <= base || late.Delay <= early.Delay {
+		t.Fatalf("delay went from %v to %v to %v, want it to grow", base, early.Delay, late.Delay)
+	}
+	if !errors.Is(late.LastError, failed) || late.LastErrorTime.IsZero() {
+		t.Fatalf("last error is %v at %v, want %v", late.LastError, late.LastErrorTime, failed)
+	}
+	if late.Frames != 1 {
+		t.Fatalf("%d frames written while writes fail, want 1", late.Frames)
+	}
+
+	// the pad is sent once writes succeed, and the delay recovers
+	dev.FailWrites(nil)
+	waitFor(t, "the pad to be sent", func() bool {
+		return dev.On(launchpad.Coord(1, 1))
+	})
+	n := 5
+	waitFor(t, "the delay to recover", func() bool {
+		n++
+		g.Pad(2, 2).SetLight(launchpad.Light{Coord: launchpad.Coord(2, 2), Effect: launchpad.EffectStatic, Color: launchpad.LightColor(n % 128)})
+		return g.RenderStats().Delay == base
+	})
+	if stats := g.RenderStats(); stats.Frames < 2 || stats.Errors < late.Errors {
+		t.Fatalf("got %d frames and %d errors after recovering", stats.Frames, stats.Errors)
+	}
+}
+
+func TestGridReplugRedraws(t *testing.T) {
+	g, dev := newTestGrid(t)
+	g.Pad(1, 1).SetLight(launchpad.Light{Coord: launchpad.Coord(1, 1), Effect: launchpad.EffectStatic, Color: 5})
+	waitFor(t, "X: 1, Y: 1 to light", func() bool {
+		return dev.On(launchpad.Coord(1, 1))
+	})
+	dev.Unplug()
+	g.Pad(2, 2).SetLight(launchpad.Light{Coord: launchpad.Coord(2, 2), Effect: launchpad.EffectStatic, Color: 5})
+	time.Sleep(50 * time.Millisecond)
+	if n := g.RenderStats().Errors; n != 0 {
+		t.Fatalf("%d frames were rendered to the unplugged device", n)
+	}
+	dev.Replug()
+	// the device comes back dark, and the grid redraws every pad
+	waitFor(t, "the grid to redraw", func() bool {
+		return dev.On(launchpad.Coord(1, 1)) && dev.On(launchpad.Coord(2, 2))
+	})
+}
+
+func TestGridSetHandlerDuringDispatch(t *testing.T) {
+	g, dev := newTestGrid(t)
+	pad := g.Pad(5, 5)
+	var a, b int32
+	var handlerA, handlerB launchpad.HitFunc
+	// each handler swaps in the other from inside the dispatch
+	handlerA = func(p *launchpad.Pad) error {
+		atomic.AddInt32(&a, 1)
+		p.SetHandler(launchpad.Press, handlerB)
+		return nil
+	}
+	handlerB = func(p *launchpad.Pad) error {
+		atomic.AddInt32(&b, 1)
+		p.SetHandler(launchpad.Press, handlerA)
+		return nil
+	}
+	pad.SetHandler(launchpad.Press, handlerA)
+	// while the release handler is swapped from outside
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			pad.SetHandler(launchpad.Release, launchpad.HitFunc(func(p *launchpad.Pad) error {
+				return nil
+			}))
+			pad.Handler(launchpad.Release)
+		}
+	}()
+	const presses = 100
+	for i := 0; i < presses; i++ {
+		dev.Press(5, 5)
+		dev.Release(5, 5)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&a)+atomic.LoadInt32(&b) < presses {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d press handlers ran", atomic.LoadInt32(&a)+atomic.LoadInt32(&b), presses)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	<-done
+	// handlers run in order, so the swaps alternate
+	if a, b := atomic.LoadInt32(&a), atomic.LoadInt32(&b); a != presses/2 || b != presses/2 {
+		t.Fatalf("handlers ran %d and %d times, want %d each", a, b, presses/2)
+	}
+}
+
+func TestGridClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	dev := fake.New()
+	g, err := launchpad.NewGrid(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetDoubleTapWindow(testWindow)
+	waiting := make(chan struct{})
+	g.Pad(1, 1).SetHandler(launchpad.Press, launchpad.HitFunc(func(p *launchpad.Pad) error {
+		close(waiting)
+		select {
+		case <-time.After(10 * time.Second):
+		case <-p.Done():
+		}
+		return nil
+	}))
+	launchpad.UseGrid(dev, g)
+	taps, presses, pressure := g.Taps(), g.Presses(), g.Pressure()
+	dev.Press(1, 1)
+	<-waiting
+
+	// a waiting handler stops when the grid is closed
+	start := time.Now()
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Close waited %v for the handler", d)
+	}
+	// every subscriber channel is closed, once drained of anything sent
+	// before Close
+	for _, ch := range []chan launchpad.Tap{taps, presses, pressure} {
+		for range ch {
+		}
+	}
+	if _, ok := <-g.Taps(); ok {
+		t.Fatal("Taps after Close returned an open channel")
+	}
+	if n := dev.Clears(); n != 1 {
+		t.Fatalf("device cleared %d times, want 1", n)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	if n := dev.Clears(); n != 1 {
+		t.Fatalf("device cleared %d times after a second Close, want 1", n)
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running, %d before", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGridCloseFromHandler(t *testing.T) {
+	g, dev := newTestGrid(t)
+	closed := make(chan error, 1)
+	g.Pad(1, 1).SetHandler(launchpad.Press, launchpad.HitFunc(func(p *launchpad.Pad) error {
+		go func() {
+			closed <- g.Close()
+		}()
+		return nil
+	}))
+	dev.Press(1, 1)
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close from a handler did not return")
+	}
+}
@@ -4,6 +4,8 @@
 package launchpad
 
 import (
+	"fmt"
+	"runtime/debug"
 	"sync"
 	"time"
 )
@@ -13,7 +15,8 @@ type Launchpad interface {
 	// Clear wipes all pads to default states and issues
 	// a device clear command.
 	Clear() error
-	// Listen collects coordinates of pad presses
+	// Listen collects coordinates of pad presses. Devices should set the
+	// Type of each Tap to Press or Release.
 	Listen() <-chan Tap
 
 	// Light applies palatte-based lights over the MIDI channel
@@ -24,13 +27,91 @@ type Launchpad interface {
 	LightSysEx([]Light) error
 }
 
+// FrameSizer is implemented by devices that can report how many bytes
+// a LightSysEx call will write. Grids use it to fill FrameStats.Bytes.
+type FrameSizer interface {
+	FrameSize([]Light) int
+}
+
+// Reconnecter is implemented by devices which reconnect by themselves
+// after being unplugged. Grids stop rendering while the device is not
+// Connected, and redraw every pad when it is Reconnected.
+type Reconnecter interface {
+	Connected() bool
+	Reconnected() <-chan struct{}
+}
+
 var (
-	// defaultRenderDelay is the amount of time the pad light
-	// rendering loop waits after each cycle.
-	// Reducing this value too much will cause distortion.
-	defaultRenderDelay = 100 * time.Millisecond
+	// defaultRenderDelay is the minimum amount of time between two
+	// frames sent by the pad light rendering loop, 30 frames per second.
+	// Only changed pads are sent, but reducing this value too much will
+	// still cause distortion.
+	defaultRenderDelay = time.Second / 30
+	// maxRenderDelay is the longest the rendering loop will back off
+	// to while writes to the device are failing.
+	maxRenderDelay = 2 * time.Second
+	// defaultDoubleTapWindow is how long the grid waits after a tap
+	// for a second tap before deciding it was a single tap.
+	defaultDoubleTapWindow = 200 * time.Millisecond
+	// defaultHoldThreshold is how long a pad must be held down
+	// to be reported as a HoldTap.
+	defaultHoldThreshold = 500 * time.Millisecond
 )
 
+const (
+	// subscriberBuffer is the buffer size of channels returned by
+	// Grid.Taps and Grid.Presses.
+	subscriberBuffer = 64
+)
+
+// ErrorHandler receives errors from a Grid's goroutines
+type ErrorHandler func(error)
+
+// RenderError is sent to a Grid's ErrorHandler when a frame could not be
+// written to the device.
+type RenderError struct {
+	// Pads is the number of pads in the frame
+	Pads int
+	Err  error
+}
+
+func (e *RenderError) Error() string {
+	return fmt.Sprintf("launchpad: rendering %d pads: %v", e.Pads, e.Err)
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// HandlerError is sent to a Grid's ErrorHandler when a HitHandler
+// returns an error or panics.
+type HandlerError struct {
+	Coordinate Coordinate
+	Type       TapType
+	Err        error
+}
+
+func (e *HandlerError) Error() string {
+	x, y := e.Coordinate.XY()
+	return fmt.Sprintf("launchpad: %s handler at X: %d, Y: %d: %v", e.Type, x, y, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is a recovered panic from a HitHandler
+type PanicError struct {
+	// Value is the value passed to panic
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
 // This is designed similarly to http.HandlerFunc
 type HitHandler interface {
 	Apply(*Pad) error
@@ -48,46 +129,225 @@ func (f HitFunc) Apply(p *Pad) error {
 // NewPad returns an empty, default Pad
 func NewPad() *Pad {
 	return &Pad{
-		SingleTapHandler: HitFunc(func(p *Pad) error {
+		singleTapHandler: HitFunc(func(p *Pad) error {
+			return nil
+		}),
+		doubleTapHandler: HitFunc(func(p *Pad) error {
+			return nil
+		}),
+		holdHandler: HitFunc(func(p *Pad) error {
+			return nil
+		}),
+		onPress: HitFunc(func(p *Pad) error {
 			return nil
 		}),
-		DoubleTapHandler: HitFunc(func(p *Pad) error {
+		onRelease: HitFunc(func(p *Pad) error {
+			return nil
+		}),
+		onPressure: HitFunc(func(p *Pad) error {
 			return nil
 		}),
 		hitFuncMu: &sync.Mutex{},
+		handlerMu: &sync.RWMutex{},
+		lightMu:   &sync.RWMutex{},
+		onChange:  func() {},
+		touchMu:   &sync.RWMutex{},
 	}
 }
 
 // Pads are the buttons on the Launchpad device
+//
+// Pads are safe for concurrent use. The Light of a Pad is read and written
+// as a whole with Light, SetLight and UpdateLight.
 type Pad struct {
-	Light
-	// HitFuncs are triggered when the pad has been presesed
-	SingleTapHandler HitHandler
-	DoubleTapHandler HitHandler
+	light Light
+	// HitFuncs are triggered when the pad has been presesed. They are
+	// set with SetHandler and read with Handler.
+	singleTapHandler HitHandler
+	doubleTapHandler HitHandler
+	// holdHandler is triggered for both HoldTap and HoldRepeat taps.
+	holdHandler HitHandler
+	// onPress and onRelease are triggered as soon as the pad is pressed
+	// down or lifted, without waiting for the tap to be classified.
+	onPress   HitHandler
+	onRelease HitHandler
+	// onPressure is triggered when the aftertouch pressure on a held pad
+	// changes. The pressure is read with Pad.Pressure.
+	onPressure HitHandler
 	// Only one HitFunc should ever be launched at a time.
 	hitFuncMu *sync.Mutex
+	// handlerMu guards the handler fields
+	handlerMu *sync.RWMutex
+	// lightMu guards light
+	lightMu *sync.RWMutex
+	// onChange is called after the light changes, so the grid can
+	// schedule a frame.
+	onChange func()
+	// done is closed when the pad's grid is closed
+	done <-chan struct{}
+
+	// velocity and pressure are recorded from the latest Press and
+	// Aftertouch taps.
+	velocity int
+	pressure int
+	// touchMu guards velocity and pressure
+	touchMu *sync.RWMutex
+}
+
+// Velocity returns the velocity the pad was last pressed with, 0-127.
+func (p *Pad) Velocity() int {
+	p.touchMu.RLock()
+	defer p.touchMu.RUnlock()
+	return p.velocity
+}
+
+// Pressure returns the current aftertouch pressure on the pad, 0-127.
+// Pressure is 0 once the pad has been released.
+func (p *Pad) Pressure() int {
+	p.touchMu.RLock()
+	defer p.touchMu.RUnlock()
+	return p.pressure
+}
+
+// Done returns a channel which is closed when the Pad's grid is closed.
+// HitHandlers which wait, such as to turn a light off again, should stop
+// waiting when it is closed. Pads made with NewPad are never done.
+func (p *Pad) Done() <-chan struct{} {
+	return p.done
+}
+
+// touch records the velocity or pressure of a tap on the pad.
+func (p *Pad) touch(t Tap) {
+	p.touchMu.Lock()
+	defer p.touchMu.Unlock()
+	switch t.Type {
+	case Press:
+		p.velocity = t.Velocity
+		p.pressure = 0
+	case Release:
+		p.pressure = 0
+	case Aftertouch:
+		p.pressure = t.Pressure
+	}
+}
+
+// Light returns a copy of the Pad's Light
+func (p *Pad) Light() Light {
+	p.lightMu.RLock()
+	defer p.lightMu.RUnlock()
+	return p.light
+}
+
+// SetLight replaces the Pad's Light
+func (p *Pad) SetLight(l Light) {
+	p.lightMu.Lock()
+	p.light = l
+	p.lightMu.Unlock()
+	p.onChange()
+}
+
+// UpdateLight atomically modifies the Pad's Light with f.
+//
+//	pad.UpdateLight(func(l *launchpad.Light) {
+//		l.RGB(127, 0, 0)
+//		l.Pulse()
+//	})
+func (p *Pad) UpdateLight(f func(*Light)) {
+	p.lightMu.Lock()
+	f(&p.light)
+	p.lightMu.Unlock()
+	p.onChange()
+}
+
+// Handler returns the HitHandler for a TapType
+func (p *Pad) Handler(t TapType) HitHandler {
+	p.handlerMu.RLock()
+	defer p.handlerMu.RUnlock()
+	switch t {
+	case SingleTap:
+		return p.singleTapHandler
+	case DoubleTap:
+		return p.doubleTapHandler
+	case HoldTap, HoldRepeat:
+		return p.holdHandler
+	case Press:
+		return p.onPress
+	case Release:
+		return p.onRelease
+	case Aftertouch:
+		return p.onPressure
+	}
+	return nil
+}
+
+// SetHandler sets the HitHandler for a TapType. It is safe to call
+// SetHandler while the grid is in use, including from a HitHandler.
+func (p *Pad) SetHandler(t TapType, h HitHandler) {
+	p.handlerMu.Lock()
+	defer p.handlerMu.Unlock()
+	switch t {
+	case SingleTap:
+		p.singleTapHandler = h
+	case DoubleTap:
+		p.doubleTapHandler = h
+	case HoldTap, HoldRepeat:
+		p.holdHandler = h
+	case Press:
+		p.onPress = h
+	case Release:
+		p.onRelease = h
+	case Aftertouch:
+		p.onPressure = h
+	}
+}
+
+// apply runs the handler for a TapType, ensuring only one handler
+// runs on the Pad at a time. A panicking handler is recovered and
+// returned as a *PanicError.
+func (p *Pad) apply(t TapType) (err error) {
+	h := p.Handler(t)
+	if h == nil {
+		return nil
+	}
+	p.hitFuncMu.Lock()
+	defer p.hitFuncMu.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return h.Apply(p)
 }
 
 type Tap struct {
 	// Times returns the time of a button press
 	Time time.Time
-	// DecisionTime returns the time a button press is categorized (single vs double)
+	// DecisionTime returns the time a button press is categorized (single, double or hold)
 	// and decided, which is based on a state machine with a ~200ms input lag.
 	DecisionTime time.Time
-	// TapType returns the type of tap that was detected, be it single or double.
+	// TapType returns the type of tap that was detected, be it single, double or hold.
 	Type TapType
 	// Coordinate is the location of the tap
 	Coordinate Coordinate
 	// X and Y are provided for developer convenience, and derive from Coordinate.
 	X int
 	Y int
+	// Region is the part of the device the tap came from, and derives from Coordinate.
+	Region Region
 	// HoldDuration is the amonut of time between button press and button lift events.
 	// A HoldDuration for a sigle tap should be ~35ms
 	// A HoldDuration for a button hould should be +100ms
-	//BUG: there is a bug that can cause the HoldDuration to become the time since the
-	// previous button lift, not since the previous button press.
+	// For HoldTap and HoldRepeat taps, HoldDuration is how long the pad has been held so far.
+	//BUG: for devices which do not report Press and Release taps, there is a bug that
+	// can cause the HoldDuration to become the time since the previous button lift,
+	// not since the previous button press.
 	// See: UseGrid() for more details
 	HoldDuration time.Duration
+	// Velocity is how hard the pad was pressed, 0-127. Devices without
+	// velocity sensitivity report 127 on press.
+	Velocity int
+	// Pressure is the aftertouch pressure of an Aftertouch tap, 0-127.
+	Pressure int
 }
 
 type TapType int
@@ -95,4 +355,36 @@ type TapType int
 const (
 	SingleTap TapType = iota
 	DoubleTap
+	// HoldTap is sent once a pad has been held down for the grid's hold threshold.
+	HoldTap
+	// HoldRepeat is sent repeatedly after a HoldTap while the pad stays down,
+	// if the grid has a hold repeat interval.
+	HoldRepeat
+	// Press and Release are raw button events reported by the device as soon
+	// as a pad is pressed down or lifted.
+	Press
+	Release
+	// Aftertouch reports a change in pressure on a held pad, or on the
+	// whole device for channel aftertouch.
+	Aftertouch
 )
+
+func (t TapType) String() string {
+	switch t {
+	case SingleTap:
+		return "single tap"
+	case DoubleTap:
+		return "double tap"
+	case HoldTap:
+		return "hold"
+	case HoldRepeat:
+		return "hold repeat"
+	case Press:
+		return "press"
+	case Release:
+		return "release"
+	case Aftertouch:
+		return "aftertouch"
+	}
+	return fmt.Sprintf("TapType(%d)", int(t))
+}
@@ -1,5 +1,9 @@
 package launchpad
 
+import (
+	"github.com/eriner/launchpad/pkg/color"
+)
+
 // LightEffects are effects applied to pad lights and are one of:
 // EffectOff, EffectStatic, EffectFlash, EffectPulse.
 type LightEffect int64
@@ -22,10 +26,12 @@ type LightColor int64
 type Light struct {
 	Effect LightEffect
 	Color  LightColor
-	Coord  Coordinate
-	R      int8
-	G      int8
-	B      int8
+	// FlashColor is the color EffectFlash flashes to from Color
+	FlashColor LightColor
+	Coord      Coordinate
+	R          int8
+	G          int8
+	B          int8
 
 	// DisplayLocked prevents Light redraws while true
 	DisplayLocked bool
@@ -39,20 +45,21 @@ func (l *Light) ToggleDisplayLock() {
 	l.DisplayLocked = !l.DisplayLocked
 }
 
-// RGB sets RGB values on a Light
+// RGB sets 0-127 RGB values on a Light. Negative values are treated as 0.
 func (l *Light) RGB(r, g, b int8) {
-	if r < 0 {
-		r = -r
-	}
-	if g < 0 {
-		g = -g
-	}
-	if b < 0 {
-		b = -b
-	}
-	l.R = r
-	l.G = g
-	l.B = b
+	l.SetRGB(color.RGB(int(r), int(g), int(b)))
+}
+
+// SetRGB sets the RGB values of a Light from a color.Color
+func (l *Light) SetRGB(c color.Color) {
+	l.R = int8(c.R)
+	l.G = int8(c.G)
+	l.B = int8(c.B)
+}
+
+// RGBColor returns the RGB values of a Light as a color.Color
+func (l *Light) RGBColor() color.Color {
+	return color.RGB(int(l.R), int(l.G), int(l.B))
 }
 
 // Static uses static lights
@@ -70,7 +77,9 @@ func (l *Light) Pulse() {
 	l.Effect = EffectPulse
 }
 
-// Flash flashes between two colors
+// Flash flashes between two palette colors, from a to b
 func (l *Light) Flash(a, b LightColor) {
-	//TODO
+	l.Effect = EffectFlash
+	l.Color = a
+	l.FlashColor = b
 }
@@ -0,0 +1,32 @@
+package launchpad_test
+
+import (
+	"testing"
+
+	"github.com/eriner/launchpad"
+	"github.com/eriner/launchpad/pkg/color"
+)
+
+func TestLightRGB(t *testing.T) {
+	tests := []struct {
+		name    string
+		r, g, b int8
+		want    color.Color
+	}{
+		{"in range", 0, 64, 127, color.Color{R: 0, G: 64, B: 127}},
+		{"negative", -1, -64, 32, color.Color{R: 0, G: 0, B: 32}},
+		{"most negative", -128, -128, -128, color.Black},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l launchpad.Light
+			l.RGB(tt.r, tt.g, tt.b)
+			if got := l.RGBColor(); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			if l.R < 0 || l.G < 0 || l.B < 0 {
+				t.Fatalf("light has negative values %d, %d, %d", l.R, l.G, l.B)
+			}
+		})
+	}
+}
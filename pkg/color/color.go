@@ -0,0 +1,191 @@
+// color provides RGB colors in the Launchpad's 0-127 range
+package color
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Max is the brightest value of a color channel on the device
+const Max = 127
+
+// Color is an RGB color in the device's 0-127 range
+type Color struct {
+	R uint8
+	G uint8
+	B uint8
+}
+
+// Clamp limits v to the device's 0-127 range
+func Clamp(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > Max {
+		return Max
+	}
+	return uint8(v)
+}
+
+// RGB returns a Color from 0-127 values, clamping values out of range
+func RGB(r, g, b int) Color {
+	return Color{Clamp(r), Clamp(g), Clamp(b)}
+}
+
+// RGB255 returns a Color from 0-255 values
+func RGB255(r, g, b uint8) Color {
+	return Color{from255(r), from255(g), from255(b)}
+}
+
+// HSV returns a Color from a hue in degrees and a saturation and value
+// between 0 and 1.
+func HSV(h, s, v float64) Color {
+	s, v = unit(s), unit(v)
+	c := v * s
+	return hue(h, c, v-c)
+}
+
+// HSL returns a Color from a hue in degrees and a saturation and
+// lightness between 0 and 1.
+func HSL(h, s, l float64) Color {
+	s, l = unit(s), unit(l)
+	c := (1 - math.Abs(2*l-1)) * s
+	return hue(h, c, l-c/2)
+}
+
+// Hex parses a color from a hex string such as "#ff8800", "ff8800" or "#f80".
+// Hex colors are 0-255 and are scaled into the device's range.
+func Hex(s string) (Color, error) {
+	h := strings.TrimPrefix(s, "#")
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+	if len(h) != 6 {
+		return Color{}, fmt.Errorf("color: invalid hex color %q", s)
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("color: invalid hex color %q", s)
+	}
+	return RGB255(uint8(v>>16), uint8(v>>8), uint8(v)), nil
+}
+
+// Parse returns a named color or parses a hex color
+func Parse(s string) (Color, error) {
+	if c, ok := Named(s); ok {
+		return c, nil
+	}
+	return Hex(s)
+}
+
+// RGB255 returns the color as 0-255 values
+func (c Color) RGB255() (r, g, b uint8) {
+	return to255(c.R), to255(c.G), to255(c.B)
+}
+
+// Hex returns the color as a hex string such as "#ff8800". Colors only have
+// 7 bits per channel, so this may differ slightly from a parsed hex string.
+func (c Color) Hex() string {
+	r, g, b := c.RGB255()
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
+
+// HSV returns the hue of the color in degrees, and its saturation and
+// value between 0 and 1.
+func (c Color) HSV() (h, s, v float64) {
+	r, g, b := float64(c.R)/Max, float64(c.G)/Max, float64(c.B)/Max
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	d := max - min
+	switch {
+	case d == 0:
+		h = 0
+	case max == r:
+		h = 60 * math.Mod((g-b)/d, 6)
+	case max == g:
+		h = 60 * ((b-r)/d + 2)
+	default:
+		h = 60 * ((r-g)/d + 4)
+	}
+	if h < 0 {
+		h += 360
+	}
+	if max > 0 {
+		s = d / max
+	}
+	return h, s, max
+}
+
+// Lerp linearly interpolates between c and o. t is between 0 (c) and 1 (o).
+func (c Color) Lerp(o Color, t float64) Color {
+	t = unit(t)
+	mix := func(a, b uint8) uint8 {
+		return Clamp(int(math.Round(float64(a) + (float64(b)-float64(a))*t)))
+	}
+	return Color{mix(c.R, o.R), mix(c.G, o.G), mix(c.B, o.B)}
+}
+
+// Dim scales the brightness of the color by f, between 0 (off) and 1
+// (unchanged).
+func (c Color) Dim(f float64) Color {
+	return Color{}.Lerp(c, f)
+}
+
+// Invert returns the color with every channel inverted
+func (c Color) Invert() Color {
+	return Color{Max - c.R, Max - c.G, Max - c.B}
+}
+
+// Complement returns the color with the opposite hue, keeping its
+// saturation and value.
+func (c Color) Complement() Color {
+	h, s, v := c.HSV()
+	return HSV(h+180, s, v)
+}
+
+// hue builds a color from a hue in degrees, a chroma c and a value m
+// added to every channel, all between 0 and 1.
+func hue(h, c, m float64) Color {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	scale := func(v float64) uint8 {
+		return Clamp(int(math.Round((v + m) * Max)))
+	}
+	return Color{scale(r), scale(g), scale(b)}
+}
+
+// unit limits v between 0 and 1
+func unit(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func from255(v uint8) uint8 {
+	return uint8((int(v)*Max + 127) / 255)
+}
+
+func to255(v uint8) uint8 {
+	if v > Max {
+		v = Max
+	}
+	return uint8((int(v)*255 + 63) / Max)
+}
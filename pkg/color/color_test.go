@@ -0,0 +1,251 @@
+package color
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHex(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Color
+	}{
+		{"#ff8800", Color{127, 68, 0}},
+		{"ff8800", Color{127, 68, 0}},
+		{"#f80", Color{127, 68, 0}},
+		{"f80", Color{127, 68, 0}},
+		{"#FFFFFF", White},
+		{"#000", Black},
+		{"#00ff00", Green},
+		{"#808080", Color{64, 64, 64}},
+	}
+	for _, tt := range tests {
+		got, err := Hex(tt.in)
+		if err != nil {
+			t.Errorf("Hex(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Hex(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHexInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"#",
+		"#ff88",
+		"#ff88001",
+		"##ff8800",
+		"#gg8800",
+		"#ff 880",
+		"0xff88",
+		"+ff880",
+	} {
+		if c, err := Hex(in); err == nil {
+			t.Errorf("Hex(%q) = %v, want an error", in, c)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Color
+	}{
+		{"red", Red},
+		{"Grey", Gray},
+		{"#0000ff", Blue},
+		{"f80", Color{127, 68, 0}},
+	}
+	for _, tt := range tests {
+		if got, err := Parse(tt.in); err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if c, err := Parse("reddish"); err == nil {
+		t.Errorf("Parse(%q) = %v, want an error", "reddish", c)
+	}
+}
+
+func TestRGB(t *testing.T) {
+	tests := []struct {
+		r, g, b int
+		want    Color
+	}{
+		{0, 64, 127, Color{0, 64, 127}},
+		{-1, -128, 0, Black},
+		{128, 255, 1000, White},
+	}
+	for _, tt := range tests {
+		if got := RGB(tt.r, tt.g, tt.b); got != tt.want {
+			t.Errorf("RGB(%d, %d, %d) = %v, want %v", tt.r, tt.g, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRGB255RoundTrip(t *testing.T) {
+	// every device color survives a trip through 0-255 and hex
+	for v := 0; v <= Max; v++ {
+		c := Color{uint8(v), uint8(v), uint8(v)}
+		r, g, b := c.RGB255()
+		if got := RGB255(r, g, b); got != c {
+			t.Errorf("RGB255(%v.RGB255()) = %v", c, got)
+		}
+		if got, err := Hex(c.Hex()); err != nil || got != c {
+			t.Errorf("Hex(%q) = %v, %v, want %v", c.Hex(), got, err, c)
+		}
+	}
+	// and 0-255 values come back within one step of where they started
+	for v := 0; v < 256; v++ {
+		r, _, _ := RGB255(uint8(v), 0, 0).RGB255()
+		if d := int(r) - v; d < -1 || d > 1 {
+			t.Errorf("%d came back as %d", v, r)
+		}
+	}
+	tests := []struct {
+		c    Color
+		want string
+	}{
+		{Black, "#000000"},
+		{White, "#ffffff"},
+		{Red, "#ff0000"},
+		{Color{127, 68, 0}, "#ff8900"},
+	}
+	for _, tt := range tests {
+		if got := tt.c.Hex(); got != tt.want {
+			t.Errorf("%v.Hex() = %q, want %q", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestHSVHSL(t *testing.T) {
+	tests := []struct {
+		h    float64
+		want Color
+	}{
+		{0, Red},
+		{60, Yellow},
+		{120, Green},
+		{180, Cyan},
+		{240, Blue},
+		{300, Magenta},
+		{360, Red},
+		{-120, Blue},
+		{480, Green},
+	}
+	for _, tt := range tests {
+		if got := HSV(tt.h, 1, 1); got != tt.want {
+			t.Errorf("HSV(%v, 1, 1) = %v, want %v", tt.h, got, tt.want)
+		}
+		if got := HSL(tt.h, 1, 0.5); got != tt.want {
+			t.Errorf("HSL(%v, 1, 0.5) = %v, want %v", tt.h, got, tt.want)
+		}
+	}
+	others := []struct {
+		name string
+		got  Color
+		want Color
+	}{
+		{"HSV without saturation", HSV(90, 0, 1), White},
+		{"HSV without value", HSV(90, 1, 0), Black},
+		{"HSV out of range", HSV(0, 2, 2), Red},
+		{"HSV below range", HSV(0, -1, -1), Black},
+		{"HSL without saturation", HSL(90, 0, 1), White},
+		{"HSL without lightness", HSL(90, 1, 0), Black},
+		{"HSL at full lightness", HSL(90, 1, 1), White},
+		{"HSL out of range", HSL(0, 2, 0.5), Red},
+	}
+	for _, tt := range others {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestColorHSV(t *testing.T) {
+	tests := []struct {
+		c       Color
+		h, s, v float64
+	}{
+		{Black, 0, 0, 0},
+		{White, 0, 0, 1},
+		{Red, 0, 1, 1},
+		{Cyan, 180, 1, 1},
+		{Magenta, 300, 1, 1},
+		{Color{0, 0, 64}, 240, 1, 64.0 / Max},
+	}
+	for _, tt := range tests {
+		h, s, v := tt.c.HSV()
+		if math.Abs(h-tt.h) > 1e-9 || math.Abs(s-tt.s) > 1e-9 || math.Abs(v-tt.v) > 1e-9 {
+			t.Errorf("%v.HSV() = %v, %v, %v, want %v, %v, %v", tt.c, h, s, v, tt.h, tt.s, tt.v)
+		}
+	}
+}
+
+func TestLerp(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Color
+		want Color
+	}{
+		{"start", Red.Lerp(Blue, 0), Red},
+		{"end", Red.Lerp(Blue, 1), Blue},
+		{"middle", Black.Lerp(White, 0.5), Color{64, 64, 64}},
+		{"before the start", Red.Lerp(Blue, -1), Red},
+		{"past the end", Red.Lerp(Blue, 2), Blue},
+		{"same color", Orange.Lerp(Orange, 0.3), Orange},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestDim(t *testing.T) {
+	tests := []struct {
+		f    float64
+		want Color
+	}{
+		{0, Black},
+		{0.5, Color{64, 32, 0}},
+		{1, Orange},
+		{-1, Black},
+		{2, Orange},
+	}
+	for _, tt := range tests {
+		if got := Orange.Dim(tt.f); got != tt.want {
+			t.Errorf("Dim(%v) = %v, want %v", tt.f, got, tt.want)
+		}
+	}
+}
+
+func TestInvertComplement(t *testing.T) {
+	tests := []struct {
+		c                  Color
+		invert, complement Color
+	}{
+		{Black, White, Black},
+		{White, Black, White},
+		{Red, Cyan, Cyan},
+		{Blue, Yellow, Yellow},
+		{Orange, Color{0, 63, 127}, Color{0, 63, 127}},
+		// gray has no hue to turn
+		{Gray, Color{63, 63, 63}, Gray},
+		// darker colors keep their value
+		{Color{64, 0, 0}, Color{63, 127, 127}, Color{0, 64, 64}},
+	}
+	for _, tt := range tests {
+		if got := tt.c.Invert(); got != tt.invert {
+			t.Errorf("%v.Invert() = %v, want %v", tt.c, got, tt.invert)
+		}
+		if got := tt.c.Complement(); got != tt.complement {
+			t.Errorf("%v.Complement() = %v, want %v", tt.c, got, tt.complement)
+		}
+		if got := tt.c.Invert().Invert(); got != tt.c {
+			t.Errorf("%v inverted twice is %v", tt.c, got)
+		}
+	}
+}
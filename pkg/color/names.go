@@ -0,0 +1,47 @@
+package color
+
+import "strings"
+
+var (
+	Black   = Color{0, 0, 0}
+	White   = Color{127, 127, 127}
+	Red     = Color{127, 0, 0}
+	Green   = Color{0, 127, 0}
+	Blue    = Color{0, 0, 127}
+	Yellow  = Color{127, 127, 0}
+	Cyan    = Color{0, 127, 127}
+	Magenta = Color{127, 0, 127}
+	Orange  = Color{127, 64, 0}
+	Purple  = Color{64, 0, 127}
+	Pink    = Color{127, 48, 96}
+	Lime    = Color{64, 127, 0}
+	Teal    = Color{0, 64, 64}
+	Amber   = Color{127, 96, 0}
+	Gray    = Color{64, 64, 64}
+)
+
+// Names is the table of named colors used by Named and Parse
+var Names = map[string]Color{
+	"black":   Black,
+	"white":   White,
+	"red":     Red,
+	"green":   Green,
+	"blue":    Blue,
+	"yellow":  Yellow,
+	"cyan":    Cyan,
+	"magenta": Magenta,
+	"orange":  Orange,
+	"purple":  Purple,
+	"pink":    Pink,
+	"lime":    Lime,
+	"teal":    Teal,
+	"amber":   Amber,
+	"gray":    Gray,
+	"grey":    Gray,
+}
+
+// Named returns a color from the Names table. Names are not case sensitive.
+func Named(name string) (Color, bool) {
+	c, ok := Names[strings.ToLower(name)]
+	return c, ok
+}
@@ -0,0 +1,261 @@
+// fake provides an in-memory Launchpad for exercising Grids without hardware.
+package fake
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/eriner/launchpad"
+)
+
+var (
+	ErrClosed    = errors.New("launchpad: fake device is closed")
+	ErrUnplugged = errors.New("launchpad: fake device is unplugged")
+)
+
+var (
+	_ launchpad.Launchpad   = (*Launchpad)(nil)
+	_ launchpad.Reconnecter = (*Launchpad)(nil)
+)
+
+// Frame is a single Light or LightSysEx call received by the fake device.
+type Frame struct {
+	// SysEx is true if the frame was sent with LightSysEx
+	SysEx  bool
+	Lights []launchpad.Light
+	Time   time.Time
+}
+
+// Launchpad is a virtual device which satisfies the launchpad.Launchpad
+// interface. It records every frame it is sent and lets callers inject
+// pad presses and releases into Listen().
+type Launchpad struct {
+	mu     sync.Mutex
+	frames []Frame
+	lit    map[launchpad.Coordinate]launchpad.Light
+	clears int
+	closed bool
+	// failErr is returned by writes while set
+	failErr error
+	// unplugged is set by Unplug, and reconnectChs are signalled by Replug
+	unplugged    bool
+	reconnectChs []chan struct{}
+
+	taps chan launchpad.Tap
+}
+
+// New returns an opened fake device.
+func New() *Launchpad {
+	return &Launchpad{
+		lit:  make(map[launchpad.Coordinate]launchpad.Light),
+		taps: make(chan launchpad.Tap, 1024),
+	}
+}
+
+// Close marks the device as closed. Further Light calls will fail.
+func (l *Launchpad) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	return nil
+}
+
+// Clear wipes the lit state of every pad.
+func (l *Launchpad) Clear() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	l.lit = make(map[launchpad.Coordinate]launchpad.Light)
+	l.clears++
+	return nil
+}
+
+// Listen returns the injected pad events.
+func (l *Launchpad) Listen() <-chan launchpad.Tap {
+	return l.taps
+}
+
+// Light records a single light frame.
+func (l *Launchpad) Light(light launchpad.Light) error {
+	return l.record(false, []launchpad.Light{light})
+}
+
+// LightSysEx records a multi-light frame.
+func (l *Launchpad) LightSysEx(lights []launchpad.Light) error {
+	return l.record(true, lights)
+}
+
+func (l *Launchpad) record(sysex bool, lights []launchpad.Light) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	if l.unplugged {
+		return ErrUnplugged
+	}
+	if l.failErr != nil {
+		return l.failErr
+	}
+	frame := Frame{
+		SysEx:  sysex,
+		Lights: make([]launchpad.Light, len(lights)),
+		Time:   time.Now(),
+	}
+	copy(frame.Lights, lights)
+	l.frames = append(l.frames, frame)
+	for _, light := range lights {
+		l.lit[light.Coord] = light
+	}
+	return nil
+}
+
+// Press injects a full velocity button press at X and Y into Listen().
+func (l *Launchpad) Press(x, y int) {
+	l.PressVelocity(x, y, 127)
+}
+
+// PressVelocity injects a button press at X and Y with a velocity of 0-127.
+func (l *Launchpad) PressVelocity(x, y, velocity int) {
+	l.inject(launchpad.Tap{
+		Type:       launchpad.Press,
+		X:          x,
+		Y:          y,
+		Coordinate: launchpad.Coord(x, y),
+		Velocity:   velocity,
+	})
+}
+
+// Release injects a button lift at X and Y into Listen().
+func (l *Launchpad) Release(x, y int) {
+	l.inject(launchpad.Tap{
+		Type:       launchpad.Release,
+		X:          x,
+		Y:          y,
+		Coordinate: launchpad.Coord(x, y),
+	})
+}
+
+// Aftertouch injects polyphonic aftertouch pressure, 0-127, at X and Y.
+func (l *Launchpad) Aftertouch(x, y, pressure int) {
+	l.inject(launchpad.Tap{
+		Type:       launchpad.Aftertouch,
+		X:          x,
+		Y:          y,
+		Coordinate: launchpad.Coord(x, y),
+		Pressure:   pressure,
+	})
+}
+
+// ChannelAftertouch injects channel aftertouch pressure, 0-127, which
+// is not tied to a pad.
+func (l *Launchpad) ChannelAftertouch(pressure int) {
+	l.inject(launchpad.Tap{
+		Type:     launchpad.Aftertouch,
+		Pressure: pressure,
+	})
+}
+
+// Tap presses and releases a button, holding it down for d.
+func (l *Launchpad) Tap(x, y int, d time.Duration) {
+	l.Press(x, y)
+	time.Sleep(d)
+	l.Release(x, y)
+}
+
+func (l *Launchpad) inject(t launchpad.Tap) {
+	t.Time = time.Now()
+	t.Region = t.Coordinate.Region()
+	l.taps <- t
+}
+
+// Frames returns a copy of every frame the device has received.
+func (l *Launchpad) Frames() []Frame {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	frames := make([]Frame, len(l.frames))
+	copy(frames, l.frames)
+	return frames
+}
+
+// Lit returns the last Light written to a Coordinate. The bool is false
+// if nothing has been written there since the last Clear.
+func (l *Launchpad) Lit(c launchpad.Coordinate) (launchpad.Light, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	light, ok := l.lit[c]
+	return light, ok
+}
+
+// On returns true if the light at a Coordinate has been written and is
+// not turned off with EffectOff.
+func (l *Launchpad) On(c launchpad.Coordinate) bool {
+	light, ok := l.Lit(c)
+	return ok && light.Effect != launchpad.EffectOff
+}
+
+// Clears returns the number of times Clear has been called.
+func (l *Launchpad) Clears() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.clears
+}
+
+// FailWrites makes Light and LightSysEx return err without recording
+// anything, until FailWrites is called with a nil error.
+func (l *Launchpad) FailWrites(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.failErr = err
+}
+
+// Connected returns false between Unplug and Replug.
+func (l *Launchpad) Connected() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return !l.unplugged
+}
+
+// Reconnected returns a channel which receives on every Replug.
+func (l *Launchpad) Reconnected() <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reconnectChs = append(l.reconnectChs, ch)
+	return ch
+}
+
+// Unplug simulates the device being disconnected. Writes fail with
+// ErrUnplugged until Replug is called.
+func (l *Launchpad) Unplug() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unplugged = true
+}
+
+// Replug simulates the device being connected again. Like a real device,
+// it comes back with every light off.
+func (l *Launchpad) Replug() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unplugged = false
+	l.lit = make(map[launchpad.Coordinate]launchpad.Light)
+	for _, ch := range l.reconnectChs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Reset forgets all recorded frames and lit state.
+func (l *Launchpad) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.frames = nil
+	l.lit = make(map[launchpad.Coordinate]launchpad.Light)
+	l.clears = 0
+}
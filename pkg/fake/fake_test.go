@@ -0,0 +1,267 @@
+package fake_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eriner/launchpad"
+	"github.com/eriner/launchpad/pkg/fake"
+)
+
+// listen returns the next event injected into dev, failing the test if
+// none arrives.
+func listen(t *testing.T, dev *fake.Launchpad) launchpad.Tap {
+	t.Helper()
+	select {
+	case tap := <-dev.Listen():
+		return tap
+	case <-time.After(time.Second):
+		t.Fatal("no event was injected")
+	}
+	return launchpad.Tap{}
+}
+
+func TestFrames(t *testing.T) {
+	dev := fake.New()
+	red := launchpad.Light{Coord: launchpad.Coord(1, 1), Effect: launchpad.EffectStatic, R: 127}
+	if err := dev.Light(red); err != nil {
+		t.Fatal(err)
+	}
+	lights := []launchpad.Light{
+		{Coord: launchpad.Coord(2, 2), Effect: launchpad.EffectPulse, Color: 5},
+		{Coord: launchpad.Coord(3, 3), Effect: launchpad.EffectOff},
+	}
+	if err := dev.LightSysEx(lights); err != nil {
+		t.Fatal(err)
+	}
+	// frames hold a copy of the lights they were sent
+	lights[0].Color = 6
+
+	frames := dev.Frames()
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	if f := frames[0]; f.SysEx || len(f.Lights) != 1 || f.Lights[0] != red {
+		t.Fatalf("Light frame is %+v", f)
+	}
+	if f := frames[1]; !f.SysEx || len(f.Lights) != 2 || f.Lights[0].Color != 5 {
+		t.Fatalf("LightSysEx frame is %+v", f)
+	}
+	if frames[0].Time.IsZero() || frames[1].Time.Before(frames[0].Time) {
+		t.Fatalf("frame times are %v and %v", frames[0].Time, frames[1].Time)
+	}
+
+	if lit, ok := dev.Lit(launchpad.Coord(1, 1)); !ok || lit != red {
+		t.Fatalf("X: 1, Y: 1 is lit %+v, %v, want %+v", lit, ok, red)
+	}
+	tests := []struct {
+		x, y int
+		on   bool
+	}{
+		{1, 1, true},
+		{2, 2, true},
+		// written, but turned off
+		{3, 3, false},
+		// never written
+		{4, 4, false},
+	}
+	for _, tt := range tests {
+		if on := dev.On(launchpad.Coord(tt.x, tt.y)); on != tt.on {
+			t.Errorf("X: %d, Y: %d is on %v, want %v", tt.x, tt.y, on, tt.on)
+		}
+	}
+
+	dev.Reset()
+	if n := len(dev.Frames()); n != 0 {
+		t.Fatalf("%d frames after Reset", n)
+	}
+	if _, ok := dev.Lit(launchpad.Coord(1, 1)); ok {
+		t.Fatal("X: 1, Y: 1 is lit after Reset")
+	}
+}
+
+func TestClear(t *testing.T) {
+	dev := fake.New()
+	if err := dev.Light(launchpad.Light{Coord: launchpad.Coord(1, 1), Effect: launchpad.EffectStatic}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := dev.Clear(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := dev.Clears(); n != 2 {
+		t.Fatalf("cleared %d times, want 2", n)
+	}
+	if _, ok := dev.Lit(launchpad.Coord(1, 1)); ok {
+		t.Fatal("X: 1, Y: 1 is lit after Clear")
+	}
+	// Clear forgets what is lit, not the frames sent
+	if n := len(dev.Frames()); n != 1 {
+		t.Fatalf("%d frames after Clear, want 1", n)
+	}
+	dev.Reset()
+	if n := dev.Clears(); n != 0 {
+		t.Fatalf("cleared %d times after Reset, want 0", n)
+	}
+}
+
+func TestClose(t *testing.T) {
+	dev := fake.New()
+	if err := dev.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.Clear(); !errors.Is(err, fake.ErrClosed) {
+		t.Fatalf("Clear got %v, want %v", err, fake.ErrClosed)
+	}
+	if err := dev.Light(launchpad.Light{}); !errors.Is(err, fake.ErrClosed) {
+		t.Fatalf("Light got %v, want %v", err, fake.ErrClosed)
+	}
+	if err := dev.LightSysEx([]launchpad.Light{{}}); !errors.Is(err, fake.ErrClosed) {
+		t.Fatalf("LightSysEx got %v, want %v", err, fake.ErrClosed)
+	}
+}
+
+func TestFailWrites(t *testing.T) {
+	dev := fake.New()
+	failed := errors.New("write failed")
+	dev.FailWrites(failed)
+	light := launchpad.Light{Coord: launchpad.Coord(1, 1), Effect: launchpad.EffectStatic}
+	if err := dev.Light(light); !errors.Is(err, failed) {
+		t.Fatalf("Light got %v, want %v", err, failed)
+	}
+	if err := dev.LightSysEx([]launchpad.Light{light}); !errors.Is(err, failed) {
+		t.Fatalf("LightSysEx got %v, want %v", err, failed)
+	}
+	if n := len(dev.Frames()); n != 0 {
+		t.Fatalf("%d frames recorded while writes fail", n)
+	}
+	if _, ok := dev.Lit(light.Coord); ok {
+		t.Fatal("X: 1, Y: 1 is lit by a failed write")
+	}
+
+	dev.FailWrites(nil)
+	if err := dev.Light(light); err != nil {
+		t.Fatal(err)
+	}
+	if !dev.On(light.Coord) {
+		t.Fatal("X: 1, Y: 1 is not on once writes recover")
+	}
+}
+
+func TestInject(t *testing.T) {
+	tests := []struct {
+		name   string
+		inject func(*fake.Launchpad)
+		want   launchpad.Tap
+	}{
+		{
+			name:   "press",
+			inject: func(l *fake.Launchpad) { l.Press(3, 4) },
+			want:   launchpad.Tap{Type: launchpad.Press, X: 3, Y: 4, Velocity: 127},
+		},
+		{
+			name:   "press with velocity",
+			inject: func(l *fake.Launchpad) { l.PressVelocity(3, 4, 90) },
+			want:   launchpad.Tap{Type: launchpad.Press, X: 3, Y: 4, Velocity: 90},
+		},
+		{
+			name:   "release",
+			inject: func(l *fake.Launchpad) { l.Release(9, 2) },
+			want:   launchpad.Tap{Type: launchpad.Release, X: 9, Y: 2},
+		},
+		{
+			name:   "aftertouch",
+			inject: func(l *fake.Launchpad) { l.Aftertouch(5, 9, 64) },
+			want:   launchpad.Tap{Type: launchpad.Aftertouch, X: 5, Y: 9, Pressure: 64},
+		},
+		{
+			name:   "channel aftertouch",
+			inject: func(l *fake.Launchpad) { l.ChannelAftertouch(32) },
+			want:   launchpad.Tap{Type: launchpad.Aftertouch, Pressure: 32},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev := fake.New()
+			tt.inject(dev)
+			got := listen(t, dev)
+			if got.Time.IsZero() {
+				t.Fatal("injected event has no time")
+			}
+			want := tt.want
+			want.Time = got.Time
+			if want.X != 0 || want.Y != 0 {
+				want.Coordinate = launchpad.Coord(want.X, want.Y)
+			}
+			want.Region = want.Coordinate.Region()
+			if got != want {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestTap(t *testing.T) {
+	dev := fake.New()
+	dev.Tap(6, 2, 10*time.Millisecond)
+	press, release := listen(t, dev), listen(t, dev)
+	if press.Type != launchpad.Press || release.Type != launchpad.Release {
+		t.Fatalf("got %s then %s, want %s then %s", press.Type, release.Type, launchpad.Press, launchpad.Release)
+	}
+	if press.Coordinate != launchpad.Coord(6, 2) || release.Coordinate != press.Coordinate {
+		t.Fatalf("got events at %v and %v, want X: 6, Y: 2", press.Coordinate, release.Coordinate)
+	}
+	if d := release.Time.Sub(press.Time); d < 10*time.Millisecond {
+		t.Fatalf("pad was held for %v, want at least 10ms", d)
+	}
+}
+
+func TestUnplugReplug(t *testing.T) {
+	dev := fake.New()
+	reconnected := dev.Reconnected()
+	light := launchpad.Light{Coord: launchpad.Coord(1, 1), Effect: launchpad.EffectStatic}
+	if err := dev.Light(light); err != nil {
+		t.Fatal(err)
+	}
+	if !dev.Connected() {
+		t.Fatal("a new device is not connected")
+	}
+
+	dev.Unplug()
+	if dev.Connected() {
+		t.Fatal("an unplugged device is connected")
+	}
+	if err := dev.Light(light); !errors.Is(err, fake.ErrUnplugged) {
+		t.Fatalf("Light got %v, want %v", err, fake.ErrUnplugged)
+	}
+	if err := dev.LightSysEx([]launchpad.Light{light}); !errors.Is(err, fake.ErrUnplugged) {
+		t.Fatalf("LightSysEx got %v, want %v", err, fake.ErrUnplugged)
+	}
+	select {
+	case <-reconnected:
+		t.Fatal("Reconnected was signalled by Unplug")
+	default:
+	}
+
+	dev.Replug()
+	if !dev.Connected() {
+		t.Fatal("a replugged device is not connected")
+	}
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("Reconnected was not signalled by Replug")
+	}
+	// the device comes back with every light off
+	if dev.On(light.Coord) {
+		t.Fatal("X: 1, Y: 1 is still on after Replug")
+	}
+	if err := dev.Light(light); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(dev.Frames()); n != 2 {
+		t.Fatalf("got %d frames, want 2", n)
+	}
+}
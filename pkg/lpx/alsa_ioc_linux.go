@@ -0,0 +1,12 @@
+//go:build (!cgo || lpx_alsa) && !mips && !mipsle && !mips64 && !mips64le && !ppc64 && !ppc64le && !sparc64
+
+package lpx
+
+// The direction bits of an ioctl request number, from <asm-generic/ioctl.h>.
+// Most architectures use these, with 14 bits for the size and 2 for the
+// direction.
+const (
+	iocWrite    = 1
+	iocRead     = 2
+	iocDirShift = 30
+)
@@ -0,0 +1,11 @@
+//go:build (!cgo || lpx_alsa) && (mips || mipsle || mips64 || mips64le || ppc64 || ppc64le || sparc64)
+
+package lpx
+
+// The direction bits of an ioctl request number on MIPS, PowerPC and SPARC,
+// which have 13 bits for the size and 3 for the direction.
+const (
+	iocWrite    = 4
+	iocRead     = 2
+	iocDirShift = 29
+)
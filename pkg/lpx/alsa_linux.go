@@ -0,0 +1,339 @@
+//go:build !cgo || lpx_alsa
+
+package lpx
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// The ALSA backend talks to the kernel's rawmidi devices directly, so it
+// needs neither cgo nor libportmidi. It is used on Linux when built
+// without cgo, or with the lpx_alsa tag.
+//
+// A Launchpad X is one rawmidi device with two subdevices, named
+// "... LPX DAW" and "... LPX MIDI". Subdevices are found and selected
+// with the ioctls of the card's control device.
+
+const (
+	rawmidiStreamOutput = 0
+	rawmidiStreamInput  = 1
+)
+
+// rawmidiInfo is struct snd_rawmidi_info from <sound/asound.h>
+type rawmidiInfo struct {
+	device          uint32
+	subdevice       uint32
+	stream          int32
+	card            int32
+	flags           uint32
+	id              [64]byte
+	name            [80]byte
+	subname         [32]byte
+	subdevicesCount uint32
+	subdevicesAvail uint32
+	reserved        [64]byte
+}
+
+// ioc encodes an ioctl request number of the ALSA control interface, as
+// _IOC does. The direction bits differ between architectures, and are set
+// in the alsa_ioc files.
+func ioc(dir, nr, size uintptr) uintptr {
+	return dir<<iocDirShift | size<<16 | 'U'<<8 | nr
+}
+
+var (
+	ctlRawmidiNextDevice      = ioc(iocRead|iocWrite, 0x40, 4)
+	ctlRawmidiInfo            = ioc(iocRead|iocWrite, 0x41, unsafe.Sizeof(rawmidiInfo{}))
+	ctlRawmidiPreferSubdevice = ioc(iocWrite, 0x42, 4)
+)
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// portID packs the card, device and subdevice of a rawmidi port
+func portID(card, device, sub int) int {
+	return card<<16 | device<<8 | sub
+}
+
+// listPorts lists the rawmidi subdevices of every sound card. Each
+// subdevice is listed once for its output and once for its input.
+func listPorts() ([]portInfo, error) {
+	ctls, err := filepath.Glob("/dev/snd/controlC*")
+	if err != nil {
+		return nil, err
+	}
+	var ports []portInfo
+	for _, ctl := range ctls {
+		card, err := strconv.Atoi(strings.TrimPrefix(ctl, "/dev/snd/controlC"))
+		if err != nil {
+			continue
+		}
+		fd, err := syscall.Open(ctl, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			// cards we may not open can't be used anyway
+			continue
+		}
+		serial := cardSerial(card)
+		for _, p := range cardPorts(fd, card) {
+			p.serial = serial
+			ports = append(ports, p)
+		}
+		syscall.Close(fd)
+	}
+	return ports, nil
+}
+
+// cardPorts lists the rawmidi subdevices of the card open on fd
+func cardPorts(fd, card int) (ports []portInfo) {
+	device := int32(-1)
+	for {
+		if err := ioctl(fd, ctlRawmidiNextDevice, unsafe.Pointer(&device)); err != nil || device < 0 {
+			return
+		}
+		for _, stream := range []int32{rawmidiStreamOutput, rawmidiStreamInput} {
+			info := rawmidiInfo{device: uint32(device), stream: stream}
+			if err := ioctl(fd, ctlRawmidiInfo, unsafe.Pointer(&info)); err != nil {
+				// the device has no ports in this direction
+				continue
+			}
+			for sub := uint32(0); sub < info.subdevicesCount; sub++ {
+				info.subdevice = sub
+				if err := ioctl(fd, ctlRawmidiInfo, unsafe.Pointer(&info)); err != nil {
+					continue
+				}
+				name := cString(info.subname[:])
+				if name == "" {
+					name = cString(info.name[:])
+				}
+				ports = append(ports, portInfo{
+					id:     portID(card, int(device), int(sub)),
+					name:   name,
+					input:  stream == rawmidiStreamInput,
+					output: stream == rawmidiStreamOutput,
+				})
+			}
+		}
+	}
+}
+
+// cardSerial reads the serial number of the USB device a sound card
+// belongs to. The card's device is one of the USB device's interfaces,
+// and the serial number is a file of the USB device. Cards which are not
+// USB devices, or have no serial number, return "".
+func cardSerial(card int) string {
+	dev, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/class/sound/card%d/device", card))
+	if err != nil {
+		return ""
+	}
+	b, err := os.ReadFile(filepath.Join(filepath.Dir(dev), "serial"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// openPort opens a rawmidi subdevice. A rawmidi subdevice is opened for
+// input and output together, so input and output are the same port.
+func openPort(input, output int) (Transport, error) {
+	if input != output {
+		return nil, errors.Errorf("launchpad: rawmidi ports %#x and %#x are not one subdevice", input, output)
+	}
+	card, device, sub := input>>16, input>>8&0xff, input&0xff
+	// the kernel keeps the preferred subdevice per thread, so the
+	// goroutine must not move to another thread until the rawmidi device
+	// has been opened
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	// the subdevice is chosen through the card's control device, which
+	// must stay open until the rawmidi device has been opened
+	ctl, err := syscall.Open(fmt.Sprintf("/dev/snd/controlC%d", card), syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(ctl)
+	prefer := int32(sub)
+	if err := ioctl(ctl, ctlRawmidiPreferSubdevice, unsafe.Pointer(&prefer)); err != nil {
+		return nil, err
+	}
+	return OpenRawMIDI(fmt.Sprintf("/dev/snd/midiC%dD%d", card, device))
+}
+
+// openDevice opens the MIDI and DAW transports of a device.
+func openDevice(dev Device) (midi, daw Transport, err error) {
+	if midi, err = openPort(dev.midiIn, dev.midiOut); err != nil {
+		return nil, nil, err
+	}
+	if daw, err = openPort(dev.dawIn, dev.dawOut); err != nil {
+		midi.Close()
+		return nil, nil, err
+	}
+	return midi, daw, nil
+}
+
+// rawTransport is a Transport over a rawmidi device, or any file which
+// carries a MIDI byte stream.
+type rawTransport struct {
+	fd int
+	// wmu keeps messages whole when they are written in parts
+	wmu    sync.Mutex
+	parser midiParser
+	buf    [256]byte
+}
+
+// OpenRawMIDI opens a Transport on a MIDI byte stream at path, such as a
+// rawmidi device like /dev/snd/midiC1D0. Devices opened this way are not
+// discovered, so they are given to New rather than Open. This is useful
+// with the snd-virmidi module, or a file standing in for a device.
+func OpenRawMIDI(path string) (Transport, error) {
+	fd, err := syscall.Open(path, syscall.O_RDWR|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "launchpad: opening %s", path)
+	}
+	return &rawTransport{fd: fd}, nil
+}
+
+func (t *rawTransport) WriteShort(status, data1, data2 int64) error {
+	b := []byte{byte(status), byte(data1), byte(data2)}
+	return t.write(b[:1+dataLen(b[0])])
+}
+
+func (t *rawTransport) WriteSysEx(msg []byte) error {
+	return t.write(msg)
+}
+
+func (t *rawTransport) write(b []byte) error {
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+	for len(b) > 0 {
+		n, err := syscall.Write(t.fd, b)
+		if err == syscall.EAGAIN {
+			// the device's buffer is full
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// Read reads everything waiting on the device without blocking.
+func (t *rawTransport) Read() (events []Event, err error) {
+	for {
+		n, err := syscall.Read(t.fd, t.buf[:])
+		if err == syscall.EAGAIN {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, t.parser.feed(t.buf[:n])...)
+		if n < len(t.buf) {
+			return events, nil
+		}
+	}
+}
+
+func (t *rawTransport) Close() error {
+	return syscall.Close(t.fd)
+}
+
+// midiParser splits a MIDI byte stream into messages.
+type midiParser struct {
+	// status is the running status, or 0 if there is none
+	status byte
+	data   []byte
+	// sysex holds the SysEx message being read while inSysEx is true
+	sysex   []byte
+	inSysEx bool
+}
+
+// feed parses bytes from the stream, returning every complete message.
+// Real-time messages are dropped.
+func (p *midiParser) feed(b []byte) (events []Event) {
+	for _, c := range b {
+		switch {
+		case c >= 0xf8:
+			// real-time messages may appear anywhere, even in SysEx
+			continue
+		case c == 0xf0:
+			p.sysex = append(p.sysex[:0], c)
+			p.inSysEx = true
+			p.status = 0
+		case c == 0xf7:
+			if p.inSysEx {
+				m := make([]byte, len(p.sysex)+1)
+				copy(m, p.sysex)
+				m[len(m)-1] = c
+				events = append(events, Event{SysEx: m})
+			}
+			p.inSysEx = false
+		case c&0x80 != 0:
+			// any other status byte ends a SysEx message
+			p.inSysEx = false
+			p.status = c
+			p.data = p.data[:0]
+			if dataLen(c) == 0 {
+				p.status = 0
+			}
+		case p.inSysEx:
+			p.sysex = append(p.sysex, c)
+		case p.status != 0:
+			p.data = append(p.data, c)
+			if len(p.data) < dataLen(p.status) {
+				continue
+			}
+			e := Event{Status: int64(p.status), Data1: int64(p.data[0])}
+			if len(p.data) > 1 {
+				e.Data2 = int64(p.data[1])
+			}
+			events = append(events, e)
+			p.data = p.data[:0]
+			if p.status >= 0xf0 {
+				// only channel messages have a running status
+				p.status = 0
+			}
+		}
+	}
+	return
+}
+
+// dataLen is the number of data bytes which follow a status byte
+func dataLen(status byte) int {
+	switch {
+	case status == 0xf1, status == 0xf3:
+		return 1
+	case status == 0xf2:
+		return 2
+	case status >= 0xf0:
+		return 0
+	case status&0xf0 == 0xc0, status&0xf0 == 0xd0:
+		return 1
+	}
+	return 2
+}
@@ -0,0 +1,183 @@
+//go:build !cgo || lpx_alsa
+
+package lpx
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMIDIParser(t *testing.T) {
+	tests := []struct {
+		name  string
+		feeds [][]byte
+		want  []Event
+	}{
+		{
+			name:  "note on",
+			feeds: [][]byte{{0x90, 11, 100}},
+			want:  []Event{{Status: 0x90, Data1: 11, Data2: 100}},
+		},
+		{
+			name:  "running status",
+			feeds: [][]byte{{0x90, 11, 100, 12, 0, 0xb4, 3, 64, 4, 0}},
+			want: []Event{
+				{Status: 0x90, Data1: 11, Data2: 100},
+				{Status: 0x90, Data1: 12, Data2: 0},
+				{Status: 0xb4, Data1: 3, Data2: 64},
+				{Status: 0xb4, Data1: 4, Data2: 0},
+			},
+		},
+		{
+			name:  "running status across reads",
+			feeds: [][]byte{{0xd0, 5}, {6}, {0xa0, 11}, {90, 12, 91}},
+			want: []Event{
+				{Status: 0xd0, Data1: 5},
+				{Status: 0xd0, Data1: 6},
+				{Status: 0xa0, Data1: 11, Data2: 90},
+				{Status: 0xa0, Data1: 12, Data2: 91},
+			},
+		},
+		{
+			name:  "real-time between data bytes",
+			feeds: [][]byte{{0x90, 0xf8, 11, 0xfe, 100}},
+			want:  []Event{{Status: 0x90, Data1: 11, Data2: 100}},
+		},
+		{
+			name:  "real-time inside SysEx",
+			feeds: [][]byte{{0xf0, 0x00, 0xf8, 0x20, 0x29}, {0xfe, 0xf7}},
+			want:  []Event{{SysEx: []byte{0xf0, 0x00, 0x20, 0x29, 0xf7}}},
+		},
+		{
+			name:  "SysEx cut short by a status byte",
+			feeds: [][]byte{{0xf0, 0x00, 0x20, 0x90, 11, 100, 0xf7}},
+			want:  []Event{{Status: 0x90, Data1: 11, Data2: 100}},
+		},
+		{
+			name:  "SysEx ends running status",
+			feeds: [][]byte{{0x90, 11, 100, 0xf0, 0x7e, 0xf7, 12, 0}},
+			want: []Event{
+				{Status: 0x90, Data1: 11, Data2: 100},
+				{SysEx: []byte{0xf0, 0x7e, 0xf7}},
+			},
+		},
+		{
+			name:  "system common has no running status",
+			feeds: [][]byte{{0xf3, 1, 2, 0xf6, 3}},
+			want:  []Event{{Status: 0xf3, Data1: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p midiParser
+			var got []Event
+			for _, b := range tt.feeds {
+				got = append(got, p.feed(b)...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataLen(t *testing.T) {
+	tests := []struct {
+		status byte
+		want   int
+	}{
+		{0x80, 2},
+		{0x9f, 2},
+		{0xa0, 2},
+		{0xb4, 2},
+		{0xc0, 1},
+		{0xd0, 1},
+		{0xe0, 2},
+		{0xf0, 0},
+		{0xf1, 1},
+		{0xf2, 2},
+		{0xf3, 1},
+		{0xf6, 0},
+		{0xf7, 0},
+		{0xf8, 0},
+	}
+	for _, tt := range tests {
+		if got := dataLen(tt.status); got != tt.want {
+			t.Errorf("dataLen(%#02x) = %d, want %d", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestOpenRawMIDIWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "midi")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := OpenRawMIDI(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.WriteShort(0x90, 11, 100); err != nil {
+		t.Fatal(err)
+	}
+	// channel aftertouch has one data byte, so the last is not written
+	if err := tr.WriteShort(0xd0, 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.WriteSysEx(LaunchpadX.msg(FunctionBrightness, []byte{0x40})); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x90, 11, 100,
+		0xd0, 5,
+		0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x08, 0x40, 0xf7,
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got % x, want % x", got, want)
+	}
+}
+
+func TestOpenRawMIDIRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "midi")
+	stream := []byte{0x90, 11, 100, 0xf0, 0x7e, 0xf8, 0x7f, 0xf7, 0x80, 11, 0}
+	if err := os.WriteFile(path, stream, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := OpenRawMIDI(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	got, err := tr.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Event{
+		{Status: 0x90, Data1: 11, Data2: 100},
+		{SysEx: []byte{0xf0, 0x7e, 0x7f, 0xf7}},
+		{Status: 0x80, Data1: 11, Data2: 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	// the whole file has been read
+	if evts, err := tr.Read(); err != nil || len(evts) != 0 {
+		t.Fatalf("got %+v, %v after the end of the stream", evts, err)
+	}
+}
+
+func TestOpenRawMIDIMissing(t *testing.T) {
+	if _, err := OpenRawMIDI(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("opened a missing file")
+	}
+}
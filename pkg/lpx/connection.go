@@ -0,0 +1,227 @@
+package lpx
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrDisconnected = errors.New("launchpad: Launchpad X is disconnected")
+)
+
+// reconnectDelay is how long the reader waits between attempts to find a
+// disconnected device again.
+var reconnectDelay = time.Second
+
+// restoredFunctions are the settings sent again to a reconnected device,
+// in order, once its mode has been restored.
+var restoredFunctions = []Function{
+	FunctionFaderSetup,
+	FunctionAftertouch,
+	FunctionVelocity,
+	FunctionLEDFeedback,
+	FunctionBrightness,
+	FunctionLEDSleep,
+}
+
+// subscriberBuffer is the buffer of each Connection channel. Events are
+// dropped for subscribers which fall this far behind.
+const subscriberBuffer = 64
+
+type ConnectionState int
+
+const (
+	Connected ConnectionState = iota
+	Disconnected
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case Connected:
+		return "connected"
+	case Disconnected:
+		return "disconnected"
+	}
+	return "unknown"
+}
+
+// ConnectionEvent reports the device being lost or found again.
+type ConnectionEvent struct {
+	Time  time.Time
+	State ConnectionState
+	// Err is the error which caused a disconnection
+	Err error
+}
+
+// Connection returns the connection state changes of the device. Each call
+// returns a new channel, which is closed when the device is closed.
+func (l *Launchpad) Connection() <-chan ConnectionEvent {
+	ch := make(chan ConnectionEvent, subscriberBuffer)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		close(ch)
+		return ch
+	}
+	l.connChs = append(l.connChs, ch)
+	return ch
+}
+
+// Reconnected returns a channel which receives every time the device is
+// found again after a disconnection, once its settings have been
+// restored. Each call returns a new channel, which is closed when the
+// device is closed. Grids use it to redraw every pad.
+func (l *Launchpad) Reconnected() <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		close(ch)
+		return ch
+	}
+	l.reconnectChs = append(l.reconnectChs, ch)
+	return ch
+}
+
+// Connected returns true unless the device has been lost.
+func (l *Launchpad) Connected() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.connected
+}
+
+// checkConnected returns ErrDisconnected while the device is lost, so
+// writes fail right away instead of going to a missing device.
+func (l *Launchpad) checkConnected() error {
+	if !l.Connected() {
+		return ErrDisconnected
+	}
+	return nil
+}
+
+// write runs f, which writes to the device's transports, unless the device
+// is lost. An error from f is taken to mean the device has been lost, and
+// is handed to the reader to disconnect. If the device is still there,
+// the reader finds it again straight away.
+func (l *Launchpad) write(f func() error) error {
+	l.io.RLock()
+	defer l.io.RUnlock()
+	if err := l.checkConnected(); err != nil {
+		return err
+	}
+	if err := f(); err != nil {
+		select {
+		case l.lost <- err:
+		default:
+		}
+		return err
+	}
+	return nil
+}
+
+// disconnect records that the device has been lost because of err and
+// closes its transports. It is safe to call more than once.
+func (l *Launchpad) disconnect(err error) {
+	l.mu.Lock()
+	if !l.connected {
+		l.mu.Unlock()
+		return
+	}
+	l.connected = false
+	l.mu.Unlock()
+	l.io.Lock()
+	l.closeTransports()
+	l.io.Unlock()
+	l.emit(ConnectionEvent{
+		Time:  time.Now(),
+		State: Disconnected,
+		Err:   err,
+	})
+}
+
+// tryReconnect makes one attempt to find and reopen a disconnected device,
+// then restores the mode, layout and program mode it had, and the settings
+// sent to it.
+func (l *Launchpad) tryReconnect() {
+	if l.reconnect == nil {
+		return
+	}
+	midi, daw, err := l.reconnect()
+	if err != nil {
+		return
+	}
+	l.io.Lock()
+	l.MIDI.Transport = midi
+	l.DAW.Transport = daw
+	l.io.Unlock()
+	l.mu.Lock()
+	l.connected = true
+	mode, layout, pm := l.mode, l.layout, l.programMode
+	settings := make(map[Function][]byte, len(l.settings))
+	for f, args := range l.settings {
+		settings[f] = args
+	}
+	l.mu.Unlock()
+	if err := l.restore(mode, layout, pm, settings); err != nil {
+		l.disconnect(err)
+		return
+	}
+	l.emit(ConnectionEvent{
+		Time:  time.Now(),
+		State: Connected,
+	})
+	l.mu.Lock()
+	for _, ch := range l.reconnectChs {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// a redraw is already pending
+		}
+	}
+	l.mu.Unlock()
+}
+
+// restore sends the cached device settings to a reconnected device. The
+// device comes back in Standalone mode and Live mode, with its default
+// settings, as if just plugged in.
+func (l *Launchpad) restore(mode DeviceMode, layout Layout, pm ProgramMode, settings map[Function][]byte) error {
+	if err := l.msg(FunctionMode, []byte{byte(mode)}); err != nil {
+		return err
+	}
+	for _, f := range restoredFunctions {
+		args, ok := settings[f]
+		if !ok {
+			continue
+		}
+		if err := l.msg(f, args); err != nil {
+			return err
+		}
+	}
+	if pm == ProgramModeProgrammer {
+		return l.msg(FunctionProgramMode, []byte{byte(pm)})
+	}
+	if !l.Profile.HasLayout(layout) {
+		return nil
+	}
+	return l.msg(FunctionLayout, []byte{byte(layout)})
+}
+
+// closeTransports closes the transports of a lost device, ignoring the
+// errors from the missing ports.
+func (l *Launchpad) closeTransports() {
+	l.MIDI.Transport.Close()
+	l.DAW.Transport.Close()
+}
+
+// emit sends a ConnectionEvent to every Connection channel
+func (l *Launchpad) emit(e ConnectionEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, ch := range l.connChs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package lpx
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// errNotPlugged is returned by replug until the test plugs the device in
+var errNotPlugged = errors.New("not plugged in yet")
+
+// replug returns a WithReconnect option which reconnects to new Loopbacks
+// once plug is closed, and a channel which receives the device end of each
+// new DAW transport.
+func replug(plug <-chan struct{}) (Option, <-chan *Loopback) {
+	daws := make(chan *Loopback, 1)
+	return WithReconnect(func() (Transport, Transport, error) {
+		select {
+		case <-plug:
+		default:
+			return nil, nil, errNotPlugged
+		}
+		midi, _ := NewLoopback()
+		daw, dev := NewLoopback()
+		daws <- dev
+		return midi, daw, nil
+	}), daws
+}
+
+// fastReconnect shortens reconnectDelay for the rest of the test
+func fastReconnect(t *testing.T) {
+	old := reconnectDelay
+	reconnectDelay = 10 * time.Millisecond
+	t.Cleanup(func() {
+		reconnectDelay = old
+	})
+}
+
+// nextConnection returns the next ConnectionEvent from ch
+func nextConnection(t *testing.T, ch <-chan ConnectionEvent) ConnectionEvent {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no connection event was received")
+	}
+	return ConnectionEvent{}
+}
+
+func TestReconnectRestores(t *testing.T) {
+	fastReconnect(t)
+	plug := make(chan struct{})
+	reconnect, daws := replug(plug)
+	lp, midi, daw := newTestLaunchpad(t, reconnect)
+	p := &LaunchpadX
+
+	// settings are sent in another order than they are restored in
+	for _, err := range []error{
+		lp.Brightness(0x20),
+		lp.LEDFeedback(true, false),
+		lp.Aftertouch(AftertouchTypeChannel, AftertouchThresholdHigh),
+		lp.Velocity(VelocityCurveFixed, 100),
+		lp.Faders(FaderVertical, []Fader{{Type: FaderBipolar, CC: 7, Color: Red}}),
+		lp.Brightness(0x30),
+		lp.Mode(ModeDAW),
+		lp.ProgramMode(ProgramModeProgrammer),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	readSysEx(t, daw)
+	conn := lp.Connection()
+	reconnected := lp.Reconnected()
+
+	// unplugging closes both ends of the transports
+	midi.Close()
+	if e := nextConnection(t, conn); e.State != Disconnected || e.Err == nil {
+		t.Fatalf("got %+v, want a disconnection with an error", e)
+	}
+	if lp.Connected() {
+		t.Fatal("Connected is true while unplugged")
+	}
+	// writes fail, but do not give up on the device
+	if err := lp.Mode(ModeStandalone); err != ErrDisconnected {
+		t.Fatalf("Mode while unplugged: got %v, want ErrDisconnected", err)
+	}
+	if err := lp.Brightness(0x7f); err != ErrDisconnected {
+		t.Fatalf("Brightness while unplugged: got %v, want ErrDisconnected", err)
+	}
+
+	close(plug)
+	dev := <-daws
+	if e := nextConnection(t, conn); e.State != Connected || e.Err != nil {
+		t.Fatalf("got %+v, want a connection", e)
+	}
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("Reconnected did not receive")
+	}
+	if !lp.Connected() {
+		t.Fatal("Connected is false after reconnecting")
+	}
+	want := [][]byte{
+		p.msg(FunctionMode, []byte{byte(ModeDAW)}),
+		p.msg(FunctionFaderSetup, []byte{0x00, byte(FaderVertical), 0x00, byte(FaderBipolar), 7, byte(Red)}),
+		p.msg(FunctionAftertouch, []byte{byte(AftertouchTypeChannel), byte(AftertouchThresholdHigh)}),
+		p.msg(FunctionVelocity, []byte{byte(VelocityCurveFixed), 100}),
+		p.msg(FunctionLEDFeedback, []byte{0x01, 0x00}),
+		p.msg(FunctionBrightness, []byte{0x30}),
+		p.msg(FunctionProgramMode, []byte{byte(ProgramModeProgrammer)}),
+	}
+	got := readSysEx(t, dev)
+	if len(got) != len(want) {
+		t.Fatalf("got % x, want % x", got, want)
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("message %d: got % x, want % x", i, got[i], want[i])
+		}
+	}
+	// the device is written to again
+	if err := lp.LEDSleep(LEDAsleep); err != nil {
+		t.Fatal(err)
+	}
+	if got := readSysEx(t, dev); len(got) != 1 || !bytes.Equal(got[0], p.msg(FunctionLEDSleep, []byte{0x00})) {
+		t.Fatalf("after reconnecting: got % x", got)
+	}
+}
+
+func TestReconnectRestoresLayout(t *testing.T) {
+	fastReconnect(t)
+	plug := make(chan struct{})
+	close(plug)
+	reconnect, daws := replug(plug)
+	lp, midi, _ := newTestLaunchpad(t, reconnect)
+	p := &LaunchpadX
+	if err := lp.Layout(LayoutCustom1); err != nil {
+		t.Fatal(err)
+	}
+	conn := lp.Connection()
+	midi.Close()
+	if e := nextConnection(t, conn); e.State != Disconnected {
+		t.Fatalf("got %+v, want a disconnection", e)
+	}
+	if e := nextConnection(t, conn); e.State != Connected {
+		t.Fatalf("got %+v, want a connection", e)
+	}
+	want := [][]byte{
+		p.msg(FunctionMode, []byte{byte(ModeStandalone)}),
+		p.msg(FunctionLayout, []byte{byte(LayoutCustom1)}),
+	}
+	got := readSysEx(t, <-daws)
+	if len(got) != len(want) {
+		t.Fatalf("got % x, want % x", got, want)
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("message %d: got % x, want % x", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCloseWhileDisconnected(t *testing.T) {
+	fastReconnect(t)
+	reconnect, _ := replug(make(chan struct{}))
+	lp, midi, _ := newTestLaunchpad(t, reconnect)
+	conn := lp.Connection()
+	reconnected := lp.Reconnected()
+	midi.Close()
+	if e := nextConnection(t, conn); e.State != Disconnected {
+		t.Fatalf("got %+v, want a disconnection", e)
+	}
+	if err := lp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for e := range conn {
+		t.Fatalf("unexpected %+v after Close", e)
+	}
+	if _, ok := <-reconnected; ok {
+		t.Fatal("Reconnected received after Close")
+	}
+}
@@ -0,0 +1,300 @@
+package lpx
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNoDevice = errors.New("launchpad: no Launchpad is connected")
+)
+
+// Device is a connected Launchpad, with its MIDI and DAW ports paired.
+type Device struct {
+	// Index is the position of the device in the list returned by Devices
+	Index int
+	// Profile is the model of the device
+	Profile *Profile
+	// MIDIName and DAWName are the names of the device's ports
+	MIDIName string
+	DAWName  string
+	// Serial is the USB serial number of the device, where the MIDI
+	// backend can read it. The ALSA backend reads it from sysfs. It is
+	// empty with PortMidi, which does not report serial numbers.
+	Serial string
+
+	// unit is the position of the device among those with the same port
+	// names, which tells apart units which are not numbered
+	unit int
+	// the ids of the inputs and outputs of the device's ports, as listed
+	// by the MIDI backend
+	midiIn, midiOut int
+	dawIn, dawOut   int
+}
+
+// portInfo is one input or output listed by the MIDI backend
+type portInfo struct {
+	id            int
+	name          string
+	input, output bool
+	// serial is the serial number of the unit the port belongs to, if the
+	// backend knows it
+	serial string
+}
+
+// port is a named port, with the ids of its input and output
+type port struct {
+	name    string
+	serial  string
+	in, out int
+	hasIn   bool
+	hasOut  bool
+}
+
+// Devices lists every connected Launchpad of the models in Profiles.
+//
+// Each unit has a MIDI and a DAW port, such as "LPX MIDI" and "LPX DAW",
+// and each of those has an input and an output. Ports are paired by the
+// rest of their name, which identifies the unit on systems that number
+// them, and by serial number where the backend knows it. Where several
+// units share the same name, their ports are paired in the order they are
+// listed.
+func Devices() ([]Device, error) {
+	infos, err := listPorts()
+	if err != nil {
+		return nil, err
+	}
+	var devices []Device
+	for _, p := range Profiles {
+		devices = pairPorts(devices, p, infos)
+	}
+	if len(devices) == 0 {
+		return nil, ErrNoDevice
+	}
+	return devices, nil
+}
+
+// pairPorts appends the devices of model p found in infos to devices.
+func pairPorts(devices []Device, p *Profile, infos []portInfo) []Device {
+	var midi, daw []port
+	for _, info := range infos {
+		switch {
+		case strings.Contains(info.name, p.MIDIPort):
+			midi = addPort(midi, info)
+		case strings.Contains(info.name, p.DAWPort):
+			daw = addPort(daw, info)
+		}
+	}
+	used := make([]bool, len(daw))
+	for _, m := range midi {
+		if !m.hasIn || !m.hasOut {
+			continue
+		}
+		unit := strings.Replace(m.name, p.MIDIPort, "", 1)
+		for i, d := range daw {
+			if used[i] || !d.hasIn || !d.hasOut {
+				continue
+			}
+			if strings.Replace(d.name, p.DAWPort, "", 1) != unit || d.serial != m.serial {
+				continue
+			}
+			used[i] = true
+			unit := 0
+			for _, dev := range devices {
+				if dev.MIDIName == m.name && dev.DAWName == d.name {
+					unit++
+				}
+			}
+			devices = append(devices, Device{
+				Index:    len(devices),
+				Profile:  p,
+				MIDIName: m.name,
+				DAWName:  d.name,
+				Serial:   m.serial,
+				unit:     unit,
+				midiIn:   m.in,
+				midiOut:  m.out,
+				dawIn:    d.in,
+				dawOut:   d.out,
+			})
+			break
+		}
+	}
+	return devices
+}
+
+// addPort records the input or output of a port. The input and output of
+// a port share its name and serial, and are listed separately, so each is
+// added to the first port of that name and serial which lacks it.
+func addPort(ports []port, info portInfo) []port {
+	for i := range ports {
+		p := &ports[i]
+		if p.name != info.name || p.serial != info.serial {
+			continue
+		}
+		if info.input && !p.hasIn {
+			p.in, p.hasIn = info.id, true
+			return ports
+		}
+		if info.output && !p.hasOut {
+			p.out, p.hasOut = info.id, true
+			return ports
+		}
+	}
+	p := port{name: info.name, serial: info.serial}
+	if info.input {
+		p.in, p.hasIn = info.id, true
+	}
+	if info.output {
+		p.out, p.hasOut = info.id, true
+	}
+	return append(ports, p)
+}
+
+// Option selects which device Open uses.
+type Option func(*openOptions)
+
+type openOptions struct {
+	index     int
+	portName  string
+	serial    string
+	profile   *Profile
+	reconnect func() (midi, daw Transport, err error)
+}
+
+// WithIndex selects the device at index i of the list returned by Devices.
+// With WithProfile or WithPortName, i is the index among the devices which
+// match.
+func WithIndex(i int) Option {
+	return func(o *openOptions) {
+		o.index = i
+	}
+}
+
+// WithPortName selects the device whose MIDI or DAW port name contains name.
+func WithPortName(name string) Option {
+	return func(o *openOptions) {
+		o.portName = name
+	}
+}
+
+// WithSerial selects the device with the USB serial number s. Serial
+// numbers are only known to the ALSA backend, so with PortMidi no device
+// is found. See Device.Serial.
+func WithSerial(s string) Option {
+	return func(o *openOptions) {
+		o.serial = s
+	}
+}
+
+// WithProfile selects only devices of model p. New uses p to talk to the
+// device, which is taken to be a Launchpad X otherwise.
+func WithProfile(p *Profile) Option {
+	return func(o *openOptions) {
+		o.profile = p
+	}
+}
+
+// WithReconnect sets how a lost device is found again. f is called
+// repeatedly while the device is disconnected, until it returns the
+// reopened MIDI and DAW transports. By default, Open reconnects to the
+// unit it selected, found by its port names, and New does not reconnect
+// unless given f.
+//
+// With the PortMidi backend, a device is only found again once no other
+// device is open. On Linux, build with the lpx_alsa tag to reconnect
+// several devices.
+func WithReconnect(f func() (midi, daw Transport, err error)) Option {
+	return func(o *openOptions) {
+		o.reconnect = f
+	}
+}
+
+// selectDevice picks a device from those connected using opts.
+func selectDevice(opts ...Option) (Device, error) {
+	devices, err := Devices()
+	if err != nil {
+		return Device{}, err
+	}
+	return pickDevice(devices, options(opts))
+}
+
+// pickDevice picks a device from devices using o.
+func pickDevice(devices []Device, o openOptions) (Device, error) {
+	if o.profile != nil {
+		var matched []Device
+		for _, d := range devices {
+			if d.Profile == o.profile {
+				matched = append(matched, d)
+			}
+		}
+		if len(matched) == 0 {
+			return Device{}, errors.Errorf("launchpad: no %s is connected", o.profile.Name)
+		}
+		devices = matched
+	}
+	if o.portName != "" {
+		var matched []Device
+		for _, d := range devices {
+			if strings.Contains(d.MIDIName, o.portName) || strings.Contains(d.DAWName, o.portName) {
+				matched = append(matched, d)
+			}
+		}
+		if len(matched) == 0 {
+			return Device{}, errors.Errorf("launchpad: no Launchpad has a port named %q", o.portName)
+		}
+		devices = matched
+	}
+	if o.serial != "" {
+		var matched []Device
+		for _, d := range devices {
+			if d.Serial == o.serial {
+				matched = append(matched, d)
+			}
+		}
+		if len(matched) == 0 {
+			return Device{}, errors.Errorf("launchpad: no Launchpad has the serial number %q", o.serial)
+		}
+		devices = matched
+	}
+	if o.index < 0 || o.index >= len(devices) {
+		return Device{}, errors.Errorf("launchpad: no Launchpad at index %d, %d connected", o.index, len(devices))
+	}
+	return devices[o.index], nil
+}
+
+// findDevice looks for dev again among the connected devices. It matches
+// the model and the serial number, if known, or else the port names and
+// the position among units with the same names, so another unit of the
+// same model is never picked up instead.
+func findDevice(dev Device) (Device, error) {
+	devices, err := Devices()
+	if err != nil {
+		return Device{}, err
+	}
+	for _, d := range devices {
+		if d.Profile != dev.Profile {
+			continue
+		}
+		if dev.Serial != "" {
+			if d.Serial == dev.Serial {
+				return d, nil
+			}
+			continue
+		}
+		if d.MIDIName == dev.MIDIName && d.DAWName == dev.DAWName && d.unit == dev.unit {
+			return d, nil
+		}
+	}
+	return Device{}, errors.Errorf("launchpad: %s is not connected", dev.MIDIName)
+}
+
+// options applies opts
+func options(opts []Option) openOptions {
+	var o openOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,161 @@
+package lpx
+
+import (
+	"testing"
+)
+
+// in and out list the input and output of a port
+func in(id int, name string) portInfo  { return portInfo{id: id, name: name, input: true} }
+func out(id int, name string) portInfo { return portInfo{id: id, name: name, output: true} }
+
+func TestPairPorts(t *testing.T) {
+	tests := []struct {
+		name  string
+		infos []portInfo
+		want  []Device
+	}{
+		{
+			name: "one unit",
+			infos: []portInfo{
+				in(0, "Launchpad X LPX DAW"), in(1, "Launchpad X LPX MIDI"),
+				out(2, "Launchpad X LPX DAW"), out(3, "Launchpad X LPX MIDI"),
+			},
+			want: []Device{
+				{Profile: &LaunchpadX, MIDIName: "Launchpad X LPX MIDI", DAWName: "Launchpad X LPX DAW", midiIn: 1, midiOut: 3, dawIn: 0, dawOut: 2},
+			},
+		},
+		{
+			name: "two units with identical names",
+			infos: []portInfo{
+				in(0, "Launchpad X LPX DAW"), in(1, "Launchpad X LPX MIDI"),
+				in(2, "Launchpad X LPX DAW"), in(3, "Launchpad X LPX MIDI"),
+				out(4, "Launchpad X LPX DAW"), out(5, "Launchpad X LPX MIDI"),
+				out(6, "Launchpad X LPX DAW"), out(7, "Launchpad X LPX MIDI"),
+			},
+			want: []Device{
+				{Profile: &LaunchpadX, MIDIName: "Launchpad X LPX MIDI", DAWName: "Launchpad X LPX DAW", midiIn: 1, midiOut: 5, dawIn: 0, dawOut: 4},
+				{Index: 1, Profile: &LaunchpadX, MIDIName: "Launchpad X LPX MIDI", DAWName: "Launchpad X LPX DAW", unit: 1, midiIn: 3, midiOut: 7, dawIn: 2, dawOut: 6},
+			},
+		},
+		{
+			name: "two numbered units",
+			infos: []portInfo{
+				in(0, "2- Launchpad X LPX MIDI"), out(1, "2- Launchpad X LPX MIDI"),
+				in(2, "Launchpad X LPX MIDI"), out(3, "Launchpad X LPX MIDI"),
+				in(4, "Launchpad X LPX DAW"), out(5, "Launchpad X LPX DAW"),
+				in(6, "2- Launchpad X LPX DAW"), out(7, "2- Launchpad X LPX DAW"),
+			},
+			want: []Device{
+				{Profile: &LaunchpadX, MIDIName: "2- Launchpad X LPX MIDI", DAWName: "2- Launchpad X LPX DAW", midiIn: 0, midiOut: 1, dawIn: 6, dawOut: 7},
+				{Index: 1, Profile: &LaunchpadX, MIDIName: "Launchpad X LPX MIDI", DAWName: "Launchpad X LPX DAW", midiIn: 2, midiOut: 3, dawIn: 4, dawOut: 5},
+			},
+		},
+		{
+			name: "units told apart by serial",
+			infos: []portInfo{
+				{id: 0, name: "Launchpad X LPX MIDI", input: true, output: true, serial: "B"},
+				{id: 1, name: "Launchpad X LPX DAW", input: true, output: true, serial: "A"},
+				{id: 2, name: "Launchpad X LPX MIDI", input: true, output: true, serial: "A"},
+				{id: 3, name: "Launchpad X LPX DAW", input: true, output: true, serial: "B"},
+			},
+			want: []Device{
+				{Profile: &LaunchpadX, MIDIName: "Launchpad X LPX MIDI", DAWName: "Launchpad X LPX DAW", Serial: "B", midiIn: 0, midiOut: 0, dawIn: 3, dawOut: 3},
+				{Index: 1, Profile: &LaunchpadX, MIDIName: "Launchpad X LPX MIDI", DAWName: "Launchpad X LPX DAW", Serial: "A", unit: 1, midiIn: 2, midiOut: 2, dawIn: 1, dawOut: 1},
+			},
+		},
+		{
+			name: "mixed models",
+			infos: []portInfo{
+				out(0, "Launchpad Mini MK3 LPMiniMK3 DAW"), out(1, "Launchpad Mini MK3 LPMiniMK3 MIDI"),
+				in(2, "Launchpad Mini MK3 LPMiniMK3 DAW"), in(3, "Launchpad Mini MK3 LPMiniMK3 MIDI"),
+				in(4, "Launchpad X LPX MIDI"), out(5, "Launchpad X LPX MIDI"),
+				in(6, "Launchpad X LPX DAW"), out(7, "Launchpad X LPX DAW"),
+				in(8, "Launchpad Pro MK3 LPProMK3 MIDI"), out(9, "Launchpad Pro MK3 LPProMK3 MIDI"),
+				in(10, "Launchpad Pro MK3 LPProMK3 DAW"), out(11, "Launchpad Pro MK3 LPProMK3 DAW"),
+				in(12, "Some Keyboard"), out(13, "Some Keyboard"),
+			},
+			want: []Device{
+				{Profile: &LaunchpadX, MIDIName: "Launchpad X LPX MIDI", DAWName: "Launchpad X LPX DAW", midiIn: 4, midiOut: 5, dawIn: 6, dawOut: 7},
+				{Index: 1, Profile: &LaunchpadMiniMK3, MIDIName: "Launchpad Mini MK3 LPMiniMK3 MIDI", DAWName: "Launchpad Mini MK3 LPMiniMK3 DAW", midiIn: 3, midiOut: 1, dawIn: 2, dawOut: 0},
+				{Index: 2, Profile: &LaunchpadProMK3, MIDIName: "Launchpad Pro MK3 LPProMK3 MIDI", DAWName: "Launchpad Pro MK3 LPProMK3 DAW", midiIn: 8, midiOut: 9, dawIn: 10, dawOut: 11},
+			},
+		},
+		{
+			name: "unit missing its DAW port",
+			infos: []portInfo{
+				in(0, "Launchpad X LPX MIDI"), out(1, "Launchpad X LPX MIDI"),
+				in(2, "2- Launchpad X LPX MIDI"), out(3, "2- Launchpad X LPX MIDI"),
+				in(4, "2- Launchpad X LPX DAW"), out(5, "2- Launchpad X LPX DAW"),
+			},
+			want: []Device{
+				{Profile: &LaunchpadX, MIDIName: "2- Launchpad X LPX MIDI", DAWName: "2- Launchpad X LPX DAW", midiIn: 2, midiOut: 3, dawIn: 4, dawOut: 5},
+			},
+		},
+		{
+			name: "unit missing a DAW output",
+			infos: []portInfo{
+				in(0, "Launchpad X LPX MIDI"), out(1, "Launchpad X LPX MIDI"),
+				in(2, "Launchpad X LPX DAW"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Device
+			for _, p := range Profiles {
+				got = pairPorts(got, p, tt.infos)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d devices, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("device %d: got %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPickDevice(t *testing.T) {
+	devices := []Device{
+		{Index: 0, Profile: &LaunchpadX, MIDIName: "Launchpad X LPX MIDI", DAWName: "Launchpad X LPX DAW", Serial: "X1"},
+		{Index: 1, Profile: &LaunchpadX, MIDIName: "2- Launchpad X LPX MIDI", DAWName: "2- Launchpad X LPX DAW", Serial: "X2"},
+		{Index: 2, Profile: &LaunchpadMiniMK3, MIDIName: "Launchpad Mini MK3 LPMiniMK3 MIDI", DAWName: "Launchpad Mini MK3 LPMiniMK3 DAW", Serial: "M1"},
+	}
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    int
+		wantErr bool
+	}{
+		{name: "first", want: 0},
+		{name: "index", opts: []Option{WithIndex(2)}, want: 2},
+		{name: "index out of range", opts: []Option{WithIndex(3)}, wantErr: true},
+		{name: "port name", opts: []Option{WithPortName("2- ")}, want: 1},
+		{name: "missing port name", opts: []Option{WithPortName("Pro")}, wantErr: true},
+		{name: "serial", opts: []Option{WithSerial("X2")}, want: 1},
+		{name: "missing serial", opts: []Option{WithSerial("X3")}, wantErr: true},
+		{name: "profile", opts: []Option{WithProfile(&LaunchpadMiniMK3)}, want: 2},
+		{name: "index within profile", opts: []Option{WithProfile(&LaunchpadX), WithIndex(1)}, want: 1},
+		{name: "missing profile", opts: []Option{WithProfile(&LaunchpadProMK3)}, wantErr: true},
+		{name: "serial of another profile", opts: []Option{WithProfile(&LaunchpadX), WithSerial("M1")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pickDevice(devices, options(tt.opts))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got device %d, want an error", got.Index)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Index != tt.want {
+				t.Fatalf("got device %d, want %d", got.Index, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package lpx
+
+import (
+	"time"
+
+	"github.com/eriner/launchpad"
+	"github.com/pkg/errors"
+)
+
+type FaderOrientation byte
+type FaderType byte
+
+const (
+	// FunctionFaderSetup configures the faders of the DAW fader layout
+	FunctionFaderSetup Function         = 0x01
+	FaderVertical      FaderOrientation = 0x00
+	FaderHorizontal    FaderOrientation = 0x01
+	FaderUnipolar      FaderType        = 0x00
+	FaderBipolar       FaderType        = 0x01
+
+	// MaxFaders is the number of faders the DAW fader layout can show
+	MaxFaders = 8
+
+	// faderChannel is the MIDI channel, zero based, that fader positions
+	// are sent and received on over the DAW interface.
+	faderChannel int64 = 4
+)
+
+// Fader describes one fader of the DAW fader layout.
+type Fader struct {
+	// Type is FaderUnipolar, which fills from one end, or FaderBipolar,
+	// which fills out from the center.
+	Type FaderType
+	// CC is the control change number, 0-127, which carries the fader's
+	// position.
+	CC byte
+	// Color is the palette color of the fader.
+	Color launchpad.LightColor
+}
+
+// FaderEvent is a fader moved on the device.
+type FaderEvent struct {
+	Time time.Time
+	// Index is the position of the fader in the slice given to Faders.
+	Index int
+	// CC is the control change number of the fader.
+	CC int
+	// Value is the fader's new position, 0-127.
+	Value int
+}
+
+// Faders sends the setup of up to MaxFaders faders to the device and
+// starts reporting their movements to FaderEvents. The faders are shown
+// once the device is in DAW mode and LayoutDAWFaders is selected.
+func (l *Launchpad) Faders(o FaderOrientation, faders []Fader) error {
+	/*
+		Host => Launchpad X:
+		Hex: F0h 00h 20h 29h 02h 0Ch 01h 00h <orientation> [<fader> <type> <CC> <colour>] F7h
+		Up to 8 fader entries may be given, each numbered 0-7.
+	*/
+	if len(faders) > MaxFaders {
+		return errors.Errorf("launchpad: %d faders given, at most %d can be shown", len(faders), MaxFaders)
+	}
+	// there is only one bank of faders
+	args := []byte{0x00, byte(o)}
+	for i, f := range faders {
+		args = append(args, byte(i), byte(f.Type), f.CC&0x7f, byte(f.Color)&0x7f)
+	}
+	if err := l.setting(FunctionFaderSetup, args); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.faders = append([]Fader(nil), faders...)
+	l.mu.Unlock()
+	return nil
+}
+
+// SetFader moves the fader at index, as given to Faders, to value, 0-127.
+func (l *Launchpad) SetFader(index, value int) error {
+	l.mu.Lock()
+	if index < 0 || index >= len(l.faders) {
+		l.mu.Unlock()
+		return errors.Errorf("launchpad: no fader %d has been set up", index)
+	}
+	cc := l.faders[index].CC
+	l.mu.Unlock()
+	if value < 0 {
+		value = 0
+	}
+	if value > 127 {
+		value = 127
+	}
+	return l.write(func() error {
+		return l.DAW.WriteShort(statusControlChange|faderChannel, int64(cc), int64(value))
+	})
+}
+
+// FaderEvents returns the movements of the faders set up with Faders.
+// Every call returns the same channel.
+func (l *Launchpad) FaderEvents() <-chan FaderEvent {
+	return l.faderEvents
+}
+
+// fader decodes a short message from the DAW interface into a FaderEvent.
+// It returns false if the message is not the position of a known fader.
+func (l *Launchpad) fader(evt Event) (FaderEvent, bool) {
+	if evt.Status != statusControlChange|faderChannel {
+		return FaderEvent{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, f := range l.faders {
+		if int64(f.CC) == evt.Data1 {
+			return FaderEvent{
+				Time:  time.Now(),
+				Index: i,
+				CC:    int(evt.Data1),
+				Value: int(evt.Data2),
+			}, true
+		}
+	}
+	return FaderEvent{}, false
+}
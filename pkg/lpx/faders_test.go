@@ -0,0 +1,158 @@
+package lpx
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFadersSysEx(t *testing.T) {
+	tests := []struct {
+		name   string
+		o      FaderOrientation
+		faders []Fader
+		want   []byte
+	}{
+		{
+			name: "vertical",
+			o:    FaderVertical,
+			faders: []Fader{
+				{Type: FaderUnipolar, CC: 7, Color: 5},
+				{Type: FaderBipolar, CC: 10, Color: 21},
+			},
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x01, 0x00, 0x00,
+				0x00, 0x00, 0x07, 0x05,
+				0x01, 0x01, 0x0a, 0x15,
+				0xf7},
+		},
+		{
+			name:   "horizontal",
+			o:      FaderHorizontal,
+			faders: []Fader{{Type: FaderBipolar, CC: 21, Color: 45}},
+			want:   []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x01, 0x00, 0x01, 0x00, 0x01, 0x15, 0x2d, 0xf7},
+		},
+		{
+			name:   "CC and color out of range",
+			o:      FaderVertical,
+			faders: []Fader{{CC: 0x80 | 0x07, Color: 0x80 | 0x05}},
+			want:   []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x01, 0x00, 0x00, 0x00, 0x00, 0x07, 0x05, 0xf7},
+		},
+		{
+			name: "none",
+			o:    FaderVertical,
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x01, 0x00, 0x00, 0xf7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp, _, daw := newTestLaunchpad(t)
+			if err := lp.Faders(tt.o, tt.faders); err != nil {
+				t.Fatal(err)
+			}
+			msgs := readSysEx(t, daw)
+			if len(msgs) != 1 || !bytes.Equal(msgs[0], tt.want) {
+				t.Fatalf("got % x, want % x", msgs, tt.want)
+			}
+		})
+	}
+}
+
+func TestFadersMax(t *testing.T) {
+	lp, _, daw := newTestLaunchpad(t)
+	if err := lp.Faders(FaderVertical, []Fader{{CC: 7}}); err != nil {
+		t.Fatal(err)
+	}
+	readSysEx(t, daw)
+	if err := lp.Faders(FaderVertical, make([]Fader, MaxFaders+1)); err == nil {
+		t.Fatalf("%d faders were accepted", MaxFaders+1)
+	}
+	if msgs := readSysEx(t, daw); len(msgs) != 0 {
+		t.Fatalf("sent % x for too many faders", msgs)
+	}
+	// the faders already set up are kept
+	if err := lp.SetFader(0, 64); err != nil {
+		t.Fatal(err)
+	}
+	if err := lp.Faders(FaderVertical, make([]Fader, MaxFaders)); err != nil {
+		t.Fatalf("%d faders: %v", MaxFaders, err)
+	}
+}
+
+func TestSetFader(t *testing.T) {
+	lp, _, daw := newTestLaunchpad(t)
+	if err := lp.Faders(FaderVertical, []Fader{{CC: 7}, {CC: 21}}); err != nil {
+		t.Fatal(err)
+	}
+	readSysEx(t, daw)
+	tests := []struct {
+		index, value int
+		want         Event
+	}{
+		{0, 64, Event{Status: 0xb4, Data1: 7, Data2: 64}},
+		{1, 100, Event{Status: 0xb4, Data1: 21, Data2: 100}},
+		{1, -5, Event{Status: 0xb4, Data1: 21, Data2: 0}},
+		{1, 300, Event{Status: 0xb4, Data1: 21, Data2: 127}},
+	}
+	for _, tt := range tests {
+		if err := lp.SetFader(tt.index, tt.value); err != nil {
+			t.Fatal(err)
+		}
+		evts, err := daw.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(evts) != 1 || !reflect.DeepEqual(evts[0], tt.want) {
+			t.Errorf("SetFader(%d, %d) sent %+v, want %+v", tt.index, tt.value, evts, tt.want)
+		}
+	}
+	for _, index := range []int{-1, 2} {
+		if err := lp.SetFader(index, 64); err == nil {
+			t.Errorf("SetFader(%d) moved a fader which was not set up", index)
+		}
+	}
+	if evts, _ := daw.Read(); len(evts) != 0 {
+		t.Fatalf("sent %+v for faders which were not set up", evts)
+	}
+}
+
+func TestFaderEvents(t *testing.T) {
+	lp, _, daw := newTestLaunchpad(t)
+	if err := lp.Faders(FaderVertical, []Fader{{CC: 7}, {CC: 21}}); err != nil {
+		t.Fatal(err)
+	}
+	// only the positions of known faders on the fader channel are events
+	for _, evt := range []Event{
+		{Status: 0xb4, Data1: 99, Data2: 10},
+		{Status: 0xb0, Data1: 21, Data2: 20},
+		{Status: 0x94, Data1: 21, Data2: 30},
+		{Status: 0xb4, Data1: 21, Data2: 64},
+		{Status: 0xb4, Data1: 7, Data2: 0},
+	} {
+		if err := daw.WriteShort(evt.Status, evt.Data1, evt.Data2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []FaderEvent{
+		{Index: 1, CC: 21, Value: 64},
+		{Index: 0, CC: 7, Value: 0},
+	} {
+		select {
+		case got := <-lp.FaderEvents():
+			if got.Time.IsZero() {
+				t.Fatalf("%+v has no time", got)
+			}
+			got.Time = time.Time{}
+			if got != want {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no fader event, want %+v", want)
+		}
+	}
+	select {
+	case got := <-lp.FaderEvents():
+		t.Fatalf("unexpected %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
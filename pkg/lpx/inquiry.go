@@ -0,0 +1,123 @@
+package lpx
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// msgVersionInquiry is Novation's bootloader and application version inquiry
+	msgVersionInquiry = []byte{0xf0, 0x00, 0x20, 0x29, 0x00, 0x70, 0xf7}
+
+	// novationID is Novation's SysEx manufacturer ID
+	novationID = []byte{0x00, 0x20, 0x29}
+)
+
+// Inquiry is the device's response to a Universal Device Inquiry.
+type Inquiry struct {
+	// Family is the device family code, and Model is the family member code.
+	Family uint16
+	Model  uint16
+	// Version is the firmware version of the running application, or of
+	// the bootloader if the device is in bootloader mode.
+	Version []byte
+	// bootloader is set when Family is the bootloader family code of the
+	// Launchpad's Profile
+	bootloader bool
+}
+
+// Bootloader is true if the device responded from its bootloader.
+func (i Inquiry) Bootloader() bool {
+	return i.bootloader
+}
+
+// DeviceInquiry sends a Universal Device Inquiry to the device and
+// waits for its response.
+func (l *Launchpad) DeviceInquiry() (Inquiry, error) {
+	/*
+		Host => Launchpad X:
+		Hex: F0h 7Eh 7Fh 06h 01h F7h
+		Launchpad X => Host:
+		Hex: F0h 7Eh 00h 06h 02h 00h 20h 29h <family: 2 bytes> <member: 2 bytes> <version: 4 bytes> F7h
+		The family code is 13h 01h in bootloader mode and 03h 01h in application mode.
+		Other models have their own family codes, which are in their Profile.
+		Each byte of the version is a digit, 0-9.
+	*/
+	var inq Inquiry
+	resp, err := l.request(msgDeviceInquiry, func(m []byte) bool {
+		return len(m) >= 17 && m[1] == 0x7e && m[3] == 0x06 && m[4] == 0x02
+	})
+	if err != nil {
+		return inq, errors.Wrap(err, "launchpad: device inquiry")
+	}
+	if !bytes.Equal(resp[5:8], novationID) {
+		return inq, errors.New("launchpad: device inquiry response is not from a Novation device")
+	}
+	inq.Family = uint16(resp[8]) | uint16(resp[9])<<8
+	inq.Model = uint16(resp[10]) | uint16(resp[11])<<8
+	inq.bootloader = inq.Family == l.Profile.BootFamily
+	inq.Version = append([]byte(nil), resp[12:16]...)
+	return inq, nil
+}
+
+// VersionInquiry sends Novation's version inquiry to the device and
+// returns the bootloader and application versions.
+func (l *Launchpad) VersionInquiry() (boot, app []byte, err error) {
+	/*
+		Host => Launchpad X:
+		Hex: F0h 00h 20h 29h 00h 70h F7h
+		Launchpad X => Host:
+		Hex: F0h 00h 20h 29h 00h 70h <bootloader version> <application version> F7h
+		Both versions are the same length, one digit per byte.
+	*/
+	resp, err := l.request(msgVersionInquiry, func(m []byte) bool {
+		return len(m) > 7 && bytes.Equal(m[:6], msgVersionInquiry[:6])
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "launchpad: version inquiry")
+	}
+	data := resp[6 : len(resp)-1]
+	if len(data)%2 != 0 {
+		return nil, nil, errors.New("launchpad: malformed version inquiry response")
+	}
+	half := len(data) / 2
+	boot = append([]byte(nil), data[:half]...)
+	app = append([]byte(nil), data[half:]...)
+	return boot, app, nil
+}
+
+// inquire fills in the device's family, model and firmware versions.
+func (l *Launchpad) inquire() error {
+	inq, err := l.DeviceInquiry()
+	if err != nil {
+		return err
+	}
+	l.Family = inq.Family
+	l.Model = inq.Model
+	if inq.Bootloader() {
+		l.BootVersion = inq.Version
+	} else {
+		l.AppVersion = inq.Version
+	}
+	// the version inquiry also reports the bootloader version, but not every
+	// firmware answers it, so a missing response isn't an error.
+	if boot, app, err := l.VersionInquiry(); err == nil {
+		l.BootVersion = boot
+		if l.AppVersion == nil {
+			l.AppVersion = app
+		}
+	}
+	return nil
+}
+
+// VersionString formats a firmware version, one digit per byte, as a string
+// such as "0421".
+func VersionString(v []byte) string {
+	var s string
+	for _, d := range v {
+		s += strconv.Itoa(int(d))
+	}
+	return s
+}
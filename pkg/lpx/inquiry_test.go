@@ -0,0 +1,143 @@
+package lpx
+
+import (
+	"bytes"
+	"testing"
+)
+
+// inquiryReply returns a device inquiry response with a family code,
+// member code and version.
+func inquiryReply(family, model uint16, version ...byte) []byte {
+	m := []byte{0xf0, 0x7e, 0x00, 0x06, 0x02, 0x00, 0x20, 0x29,
+		byte(family), byte(family >> 8), byte(model), byte(model >> 8)}
+	m = append(m, version...)
+	return append(m, 0xf7)
+}
+
+func TestDeviceInquiry(t *testing.T) {
+	tests := []struct {
+		name       string
+		profile    *Profile
+		reply      []byte
+		family     uint16
+		model      uint16
+		version    []byte
+		bootloader bool
+	}{
+		{
+			name:    "Launchpad X",
+			profile: &LaunchpadX,
+			reply:   inquiryResponse,
+			family:  0x0103,
+			version: []byte{0, 4, 2, 1},
+		},
+		{
+			name:       "Launchpad X bootloader",
+			profile:    &LaunchpadX,
+			reply:      inquiryReply(0x0113, 0x0000, 0, 1, 7, 0),
+			family:     0x0113,
+			version:    []byte{0, 1, 7, 0},
+			bootloader: true,
+		},
+		{
+			// the Mini MK3's application shares the Launchpad X's
+			// bootloader family code
+			name:    "Launchpad Mini MK3",
+			profile: &LaunchpadMiniMK3,
+			reply:   inquiryReply(0x0113, 0x0002, 0, 4, 0, 7),
+			family:  0x0113,
+			model:   0x0002,
+			version: []byte{0, 4, 0, 7},
+		},
+		{
+			name:       "Launchpad Mini MK3 bootloader",
+			profile:    &LaunchpadMiniMK3,
+			reply:      inquiryReply(0x0117, 0x0000, 0, 0, 5, 1),
+			family:     0x0117,
+			version:    []byte{0, 0, 5, 1},
+			bootloader: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp, _, daw := newTestLaunchpad(t, WithProfile(tt.profile))
+			answer(t, daw, map[string][]byte{string(msgDeviceInquiry): tt.reply})
+			inq, err := lp.DeviceInquiry()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inq.Family != tt.family || inq.Model != tt.model || !bytes.Equal(inq.Version, tt.version) {
+				t.Fatalf("got family %#04x, model %#04x, version %v, want %#04x, %#04x, %v",
+					inq.Family, inq.Model, inq.Version, tt.family, tt.model, tt.version)
+			}
+			if inq.Bootloader() != tt.bootloader {
+				t.Fatalf("got Bootloader %v, want %v", inq.Bootloader(), tt.bootloader)
+			}
+		})
+	}
+}
+
+func TestDeviceInquiryNotNovation(t *testing.T) {
+	lp, _, daw := newTestLaunchpad(t)
+	reply := append([]byte(nil), inquiryResponse...)
+	reply[7] = 0x00
+	answer(t, daw, map[string][]byte{string(msgDeviceInquiry): reply})
+	if _, err := lp.DeviceInquiry(); err == nil {
+		t.Fatal("accepted a response from another manufacturer")
+	}
+}
+
+func TestVersionInquiry(t *testing.T) {
+	lp, _, daw := newTestLaunchpad(t)
+	answer(t, daw, map[string][]byte{string(msgVersionInquiry): versionResponse})
+	boot, app, err := lp.VersionInquiry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VersionString(boot) != "0123" || VersionString(app) != "0456" {
+		t.Fatalf("got boot %s, app %s, want 0123, 0456", VersionString(boot), VersionString(app))
+	}
+}
+
+func TestVersionInquiryMalformed(t *testing.T) {
+	lp, _, daw := newTestLaunchpad(t)
+	answer(t, daw, map[string][]byte{
+		string(msgVersionInquiry): {0xf0, 0x00, 0x20, 0x29, 0x00, 0x70, 0, 1, 2, 0xf7},
+	})
+	if _, _, err := lp.VersionInquiry(); err == nil {
+		t.Fatal("accepted versions of different lengths")
+	}
+}
+
+func TestInquire(t *testing.T) {
+	lp, _, daw := newTestLaunchpad(t)
+	answer(t, daw, map[string][]byte{
+		string(msgDeviceInquiry):  inquiryResponse,
+		string(msgVersionInquiry): versionResponse,
+	})
+	if err := lp.inquire(); err != nil {
+		t.Fatal(err)
+	}
+	if lp.Family != 0x0103 || VersionString(lp.AppVersion) != "0421" || VersionString(lp.BootVersion) != "0123" {
+		t.Fatalf("got family %#04x, app %s, boot %s", lp.Family, VersionString(lp.AppVersion), VersionString(lp.BootVersion))
+	}
+}
+
+func TestSetupWithoutInquiry(t *testing.T) {
+	p := &LaunchpadX
+	lp, _, daw := newTestLaunchpad(t)
+	// the device answers everything but the inquiries
+	answer(t, daw, map[string][]byte{
+		string(p.msg(FunctionProgramMode, nil)): p.msg(FunctionProgramMode, []byte{0x00}),
+		string(p.msg(FunctionLayout, nil)):      p.msg(FunctionLayout, []byte{byte(LayoutNote)}),
+	})
+	if err := lp.setup(); err != nil {
+		t.Fatalf("setup failed without an inquiry response: %v", err)
+	}
+	if lp.InquiryErr == nil {
+		t.Fatal("InquiryErr is nil without an inquiry response")
+	}
+	if lay, err := lp.CurrentLayout(); err != nil || lay != LayoutNote {
+		t.Fatalf("got layout %v, %v, want LayoutNote", lay, err)
+	}
+}
@@ -1,22 +1,23 @@
 package lpx
 
 import (
+	"sync"
 	"time"
 
 	"github.com/eriner/launchpad"
+	"github.com/eriner/launchpad/pkg/color"
 	"github.com/pkg/errors"
-	"github.com/rakyll/portmidi"
 )
 
 var (
 	// msgDeviceInquiry doesn't follow the normal message pattern
 	msgDeviceInquiry = []byte{0xf0, 0x7e, 0x7f, 0x06, 0x01, 0xf7}
-
-	sysExPrefix = []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c}
 )
 
 var (
 	ErrWrongMode = errors.New("launchpad: Launchpad X is not in the correct mode")
+	ErrClosed    = errors.New("launchpad: Launchpad X is closed")
+	ErrTimeout   = errors.New("launchpad: timed out waiting for a SysEx response")
 )
 
 type Function byte
@@ -26,6 +27,9 @@ type DeviceMode byte
 type Aftertouch byte
 type AftertouchType byte
 type AftertouchThreshold byte
+type Brightness byte
+type VelocityCurve byte
+type LEDSleep byte
 
 const (
 	// FunctionMode changes the launchpad device mode to Standalone or DAW
@@ -66,8 +70,28 @@ const (
 	Red   launchpad.LightColor = 0x05
 
 	FunctionRGB         Function = 0x03
+	FunctionTextScroll  Function = 0x07
 	FunctionLEDFeedback Function = 0x0a
 
+	// FunctionBrightness sets the brightness of every LED, 0-127
+	FunctionBrightness Function   = 0x08
+	BrightnessMin      Brightness = 0x00
+	BrightnessMax      Brightness = 0x7f
+
+	// FunctionLEDSleep turns every LED off, or back on, without changing
+	// their state.
+	FunctionLEDSleep Function = 0x09
+	LEDAsleep        LEDSleep = 0x00
+	LEDAwake         LEDSleep = 0x01
+
+	// FunctionVelocity sets the velocity curve of the pads, and the
+	// velocity reported by every press with VelocityCurveFixed.
+	FunctionVelocity    Function      = 0x04
+	VelocityCurveLow    VelocityCurve = 0x00
+	VelocityCurveMedium VelocityCurve = 0x01
+	VelocityCurveHigh   VelocityCurve = 0x02
+	VelocityCurveFixed  VelocityCurve = 0x03
+
 	FunctionAftertouch        Function            = 0x0b
 	AftertouchTypePolymorphic AftertouchType      = 0x00 // - 0: Polyphonic Aftertouch (Key Pressure events, A0h – AFh).
 	AftertouchTypeChannel     AftertouchType      = 0x01 // - 1: Channel Aftertouch (Channel Pressure events, D0h – DFh)
@@ -79,23 +103,107 @@ const (
 	sysExSuffix byte = 0xf7
 )
 
-// Launchpad represents a device with input and output MIDI and DAW streams.
+// MIDI status bytes, without the channel nibble
+const (
+	statusNoteOff           int64 = 0x80
+	statusNoteOn            int64 = 0x90
+	statusPolyAftertouch    int64 = 0xa0
+	statusControlChange     int64 = 0xb0
+	statusChannelAftertouch int64 = 0xd0
+)
+
+// Launchpad represents a device with input and output MIDI and DAW transports.
 type Launchpad struct {
-	// MIDI contains the device MIDI controller IO streams
+	// MIDI contains the device MIDI controller transport
 	MIDI
-	// DAW contains the device DAW controller IO streams
+	// DAW contains the device DAW controller transport
 	DAW
+	// Device is the connected device the Launchpad was opened on
+	Device Device
+	// Profile is the model of the device, which sets the SysEx device ID
+	// and what the device supports
+	Profile *Profile
 	// mode is the device's current mode, either Standalone or DAW.
 	// only one mode can be active at a time..
 	mode DeviceMode
-
-	// the input and output streams will be changed from MIDI to DAW
-	// when ModeDAW() is called.
-	inputStream  *portmidi.Stream
-	outputStream *portmidi.Stream
-
+	// layout and programMode are the device's current layout and program
+	// mode. They are kept in sync by the setters and the Current getters.
+	layout      Layout
+	programMode ProgramMode
+	// settings are the arguments last sent for each of the restored
+	// functions, which are sent again when the device is reconnected
+	settings map[Function][]byte
+
+	// AppVersion and BootVersion are the firmware versions reported by
+	// the device when it is opened, one digit per byte.
 	AppVersion  []byte
 	BootVersion []byte
+	// Family and Model are the family code and family member code reported
+	// by the device when it is opened.
+	Family uint16
+	Model  uint16
+	// InquiryErr is the error from asking the device for its versions,
+	// family and model when it was opened. They are left empty if the
+	// device did not answer, but the device is still opened.
+	InquiryErr error
+
+	// taps receives pad events from the reader for Listen
+	taps chan launchpad.Tap
+	// faders are the faders last sent to the device with Faders, and
+	// faderEvents receives their movements from the reader
+	faders      []Fader
+	faderEvents chan FaderEvent
+	// waiters are Transact calls waiting for a SysEx response
+	waiters []*waiter
+	// reconnect finds a lost device again and reopens its transports. A
+	// nil reconnect leaves the device disconnected.
+	reconnect func() (midi, daw Transport, err error)
+	// connected is false while the device is lost. lost hands write
+	// errors to the reader, which disconnects the device.
+	connected bool
+	lost      chan error
+	// connChs and reconnectChs are the channels returned by Connection
+	// and Reconnected
+	connChs      []chan ConnectionEvent
+	reconnectChs []chan struct{}
+	// io guards the transports, which are replaced when the device is
+	// reconnected. Writers hold it for reading.
+	io sync.RWMutex
+	// mu guards waiters, faders, connection state, closed and the cached
+	// device state
+	mu     sync.Mutex
+	closed bool
+	// done stops the reader when the device is closed, and wg waits for it
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New returns a Launchpad which talks to a device over the MIDI and DAW
+// transports given. Unlike Open, New does not set up the device or ask for
+// its state, so it may be used with transports which are not connected to
+// a real device, such as a Loopback. Only WithProfile and WithReconnect
+// are used from opts.
+func New(midi, daw Transport, opts ...Option) *Launchpad {
+	o := options(opts)
+	if o.profile == nil {
+		o.profile = &LaunchpadX
+	}
+	lp := &Launchpad{
+		MIDI:        MIDI{Transport: midi},
+		DAW:         DAW{Transport: daw, profile: o.profile},
+		Profile:     o.profile,
+		taps:        make(chan launchpad.Tap, 1024),
+		faderEvents: make(chan FaderEvent, 1024),
+		settings:    make(map[Function][]byte),
+		done:        make(chan struct{}),
+		reconnect:   o.reconnect,
+		connected:   true,
+		lost:        make(chan error, 1),
+	}
+	// the reader separates pad events from SysEx responses
+	lp.wg.Add(1)
+	go lp.read()
+	return lp
 }
 
 // Hit represents physical touches to Launchpad buttons.
@@ -105,50 +213,93 @@ type Hit struct {
 }
 
 // Open opens a connection Launchpad and initializes an input and output
-// stream to the currently connected device. If there are no
-// devices are connected, it returns an error.
-func Open() (*Launchpad, error) {
-	midi := &MIDI{}
-	if err := midi.Open(); err != nil {
+// stream to a connected device, the first one listed by Devices unless
+// opts select another. If there are no devices are connected, it returns
+// an error. Each device may be opened once, and driven by its own Grid.
+func Open(opts ...Option) (*Launchpad, error) {
+	dev, err := selectDevice(opts...)
+	if err != nil {
 		return nil, err
 	}
-	daw := &DAW{}
-	if err := daw.Open(); err != nil {
+	midi, daw, err := openDevice(dev)
+	if err != nil {
 		return nil, err
 	}
-	lp := &Launchpad{MIDI: *midi,
-		DAW: *daw,
+	// New talks to the model which was found
+	opts = append(opts, WithProfile(dev.Profile))
+	if options(opts).reconnect == nil {
+		// look for the same unit again, rather than whichever device
+		// the options would select now
+		opts = append(opts, WithReconnect(func() (Transport, Transport, error) {
+			d, err := findDevice(dev)
+			if err != nil {
+				return nil, nil, err
+			}
+			return openDevice(d)
+		}))
 	}
-	// by default, we use Standalone mode and provide
-	// MIDI input and outputstreams
-	if err := lp.Mode(ModeStandalone); err != nil {
+	lp := New(midi, daw, opts...)
+	lp.Device = dev
+	if err := lp.setup(); err != nil {
+		lp.Close()
 		return nil, err
 	}
-	/*
-		// we also get the app and boot versions
-		if err := lp.DAW.outputStream.WriteSysExBytes(portmidi.Time(), msgDeviceInquiry); err != nil {
-			lp.Close()
-			return nil, err
-		}
-		time.Sleep(10 * time.Millisecond)
-		resp, err := lp.inputStream.ReadSysExBytes(5)
-		if err != nil {
-			panic(err)
-		}
-		//TODO: response does not correspond with "device inquiry message" in manual
-		// needs to be investigated and fixed
-		//spew.Dump(resp)
-		_ = resp
-	*/
 	return lp, nil
 }
 
+// setup puts a newly opened device in Standalone mode and asks for the
+// state it was left in.
+func (l *Launchpad) setup() error {
+	// by default, we use Standalone mode
+	if err := l.Mode(ModeStandalone); err != nil {
+		return err
+	}
+	// the layout and program mode are left over from whatever last used
+	// the device, so we ask for them. Models without selectable layouts
+	// answer the layout query in their own format, so only the program
+	// mode is asked for.
+	if _, err := l.CurrentProgramMode(); err != nil {
+		return err
+	}
+	if len(l.Profile.Layouts) > 0 {
+		if _, err := l.CurrentLayout(); err != nil {
+			return err
+		}
+	}
+	// we also get the device family and the app and boot versions. They
+	// are only informational, so a device which does not answer is still
+	// used.
+	l.InquiryErr = l.inquire()
+	return nil
+}
+
 func (l *Launchpad) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
 	// reset everything
 	l.Mode(ModeStandalone)
 	l.ProgramMode(ProgramModeLive)
-	l.inputStream = nil
-	l.outputStream = nil
+	close(l.done)
+	l.wg.Wait()
+	l.mu.Lock()
+	connected := l.connected
+	for _, ch := range l.connChs {
+		close(ch)
+	}
+	for _, ch := range l.reconnectChs {
+		close(ch)
+	}
+	l.connChs, l.reconnectChs = nil, nil
+	l.mu.Unlock()
+	if !connected {
+		// the transports of a lost device are already closed
+		return nil
+	}
 	var retErr error
 	if err := l.MIDI.Close(); err != nil {
 		retErr = err
@@ -159,47 +310,87 @@ func (l *Launchpad) Close() error {
 	return retErr
 }
 
-// Mode switches the Launchpad X into between Standalone mode and DAW mode
+// Mode switches the Launchpad X into between Standalone mode and DAW mode.
+// The mode is only cached once it has been sent, so a device which is
+// disconnected keeps the mode it had, and is restored to it on reconnecting.
 func (l *Launchpad) Mode(m DeviceMode) error {
-	l.inputStream = l.MIDI.inputStream
-	l.outputStream = l.MIDI.outputStream
 	if err := l.msg(FunctionMode, []byte{byte(m)}); err != nil {
-		// if we're ever unable to switch modes, the device is broken. and we need to abort
-		if cErr := l.Close(); err != nil {
-			return errors.Wrap(err, cErr.Error())
-		}
 		return err
 	}
+	l.mu.Lock()
+	l.mode = m
+	l.mu.Unlock()
 	return nil
 }
 
+// Layout selects one of the device's layouts. Layouts the model does not
+// have, which are not in its Profile, return ErrUnsupported.
 func (l *Launchpad) Layout(lay Layout) error {
-	return l.msg(FunctionLayout, []byte{byte(lay)})
+	if !l.Profile.HasLayout(lay) {
+		return ErrUnsupported
+	}
+	if err := l.msg(FunctionLayout, []byte{byte(lay)}); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.setLayout(lay)
+	l.mu.Unlock()
+	return nil
 }
 
 func (l *Launchpad) ProgramMode(pm ProgramMode) error {
-	return l.msg(FunctionProgramMode, []byte{byte(pm)})
+	if err := l.msg(FunctionProgramMode, []byte{byte(pm)}); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.setProgramMode(pm)
+	l.mu.Unlock()
+	return nil
 }
 
 func (l *Launchpad) Test() {
-	if err := l.MIDI.outputStream.WriteShort(0x80, 0x51, 0x00); err != nil {
+	if err := l.MIDI.WriteShort(0x80, 0x51, 0x00); err != nil {
 		panic(err)
 	}
 }
 
+// Light lights a pad over the MIDI interface. The device must be in programmer mode.
 func (l *Launchpad) Light(light launchpad.Light) error {
-	err := l.MIDI.outputStream.WriteShort(int64(light.Effect), int64(light.Coord), int64(light.Color))
+	if err := l.requireProgrammer(); err != nil {
+		return err
+	}
+	err := l.write(func() error {
+		if light.Effect == launchpad.EffectFlash {
+			// flashing lights flash from the static color on channel 1
+			// to the color on channel 2.
+			if err := l.MIDI.WriteShort(int64(launchpad.EffectStatic), int64(light.Coord), int64(light.Color)); err != nil {
+				return err
+			}
+			return l.MIDI.WriteShort(int64(launchpad.EffectFlash), int64(light.Coord), int64(light.FlashColor))
+		}
+		return l.MIDI.WriteShort(int64(light.Effect), int64(light.Coord), int64(light.Color))
+	})
 	time.Sleep(5 * time.Millisecond)
 	return err
 }
 
 func (l *Launchpad) LightSysEx(lights []launchpad.Light) error {
+	err := l.msg(FunctionRGB, colorspecs(lights))
+	return err
+}
+
+// FrameSize returns the number of bytes LightSysEx writes for lights
+func (l *Launchpad) FrameSize(lights []launchpad.Light) int {
+	return len(l.Profile.msg(FunctionRGB, colorspecs(lights)))
+}
+
+// colorspecs encodes lights for a FunctionRGB message
+func colorspecs(lights []launchpad.Light) []byte {
 	var colorspec []byte
 	for _, light := range lights {
 		colorspec = append(colorspec, LightRGBSysEx(&light)...)
 	}
-	err := l.msg(FunctionRGB, colorspec)
-	return err
+	return colorspec
 }
 
 func LightRGBSysEx(light *launchpad.Light) []byte {
@@ -224,33 +415,72 @@ func LightRGBSysEx(light *launchpad.Light) []byte {
 	case launchpad.EffectPulse:
 		out = append(out, 0x02)
 		out = append(out, byte(light.Coord))
-		out = append(out, approximatePalatte(light.R, light.G, light.B))
-
+		out = append(out, byte(PaletteColor(light.R, light.G, light.B)))
+	case launchpad.EffectFlash:
+		// Colour B is the base color and Colour A the color flashed to,
+		// matching channels 1 and 2 over MIDI.
+		out = append(out, 0x01)
+		out = append(out, byte(light.Coord))
+		out = append(out, byte(light.Color), byte(light.FlashColor))
+	case launchpad.EffectOff:
+		out = append(out, 0x00)
+		out = append(out, byte(light.Coord))
+		out = append(out, byte(Black))
 	}
 	return out
 
 }
 
-//TODO: implement this function. This is just a placeholder
-func approximatePalatte(r, g, b int8) byte {
-	return byte(White)
-}
-
-// Clear sends the clear command to the device. Note that calling Clear on the device
+// Clear turns off every light on the device. Note that calling Clear on the device
 // will be overwritten by the state of any launchpad.Grid elements
 func (l *Launchpad) Clear() error {
-	//TODO
-	return nil
+	var lights []launchpad.Light
+	for x := 1; x < 10; x++ {
+		for y := 1; y < 10; y++ {
+			lights = append(lights, launchpad.Light{
+				Coord:  launchpad.Coord(x, y),
+				Effect: launchpad.EffectStatic,
+			})
+		}
+	}
+	return l.LightSysEx(lights)
 }
 
 func (l *Launchpad) Aftertouch(attype AftertouchType, atthresh AftertouchThreshold) error {
+	if !l.Profile.Aftertouch {
+		return ErrUnsupported
+	}
 	var args []byte
 	args = append(args, byte(attype), byte(atthresh))
-	return l.msg(FunctionAftertouch, args)
+	return l.setting(FunctionAftertouch, args)
 }
 
-// LEDFeedback configures the device's LEDFeedback setting.
-// BUG: I haven't been able to get this to work for some reason.
+// Velocity configures the device's velocity curve. fixed is the velocity,
+// 1-127, reported by every press when curve is VelocityCurveFixed.
+func (l *Launchpad) Velocity(curve VelocityCurve, fixed byte) error {
+	if !l.Profile.Velocity {
+		return ErrUnsupported
+	}
+	if fixed < 1 {
+		fixed = 1
+	}
+	if fixed > 127 {
+		fixed = 127
+	}
+	var args []byte
+	args = append(args, byte(curve), fixed)
+	return l.setting(FunctionVelocity, args)
+}
+
+// LEDFeedback configures whether pads light up when pressed (internal) and
+// when MIDI notes are sent to the device (external). The device's settings
+// are read back with CurrentLEDFeedback.
+//
+// Ref: the programmer's reference describes LED feedback as a setting of
+// the Custom modes. Session, Note and Programmer mode, which Grids use,
+// light the pads their own way, so LEDFeedback has no visible effect until
+// a Custom layout is selected. This is why it was once thought not to
+// work: the bytes sent are those given in the reference.
 func (l *Launchpad) LEDFeedback(internal, external bool) error {
 	var i, e byte
 	if internal {
@@ -261,78 +491,121 @@ func (l *Launchpad) LEDFeedback(internal, external bool) error {
 	}
 	var args []byte
 	args = append(args, i, e)
-	return l.msg(FunctionLEDFeedback, args)
+	return l.setting(FunctionLEDFeedback, args)
 }
 
-// Listen returns launchpad button presses
-func (l *Launchpad) Listen() <-chan launchpad.Tap {
-	ch := make(chan launchpad.Tap)
-	go func(pad *Launchpad, ch chan launchpad.Tap) {
-		for {
-			time.Sleep(5 * time.Millisecond)
-			hits, err := pad.Read()
-			if err != nil {
-				continue
-			}
-			for i := range hits {
-				ch <- hits[i]
-			}
-		}
-	}(l, ch)
-	return ch
+// Brightness sets the brightness of every LED on the device
+func (l *Launchpad) Brightness(b Brightness) error {
+	if b > BrightnessMax {
+		b = BrightnessMax
+	}
+	return l.setting(FunctionBrightness, []byte{byte(b)})
+}
+
+// LEDSleep puts the device's LEDs to sleep with LEDAsleep, or wakes them
+// with LEDAwake. Lights written while asleep are shown on waking.
+func (l *Launchpad) LEDSleep(s LEDSleep) error {
+	return l.setting(FunctionLEDSleep, []byte{byte(s)})
 }
 
 // Read returns events from the MIDI stream. This includes button presses
+// and releases. Read is called by the reader started by Open, which sends
+// the events to Listen, so most programs should use Listen instead.
 func (l *Launchpad) Read() (taps []launchpad.Tap, err error) {
-	var evts []portmidi.Event
-	if evts, err = l.MIDI.inputStream.Read(64); err != nil {
+	var evts []Event
+	if evts, err = l.MIDI.Read(); err != nil {
 		return
 	}
 	for _, evt := range evts {
-		i := int(evt.Data1)
-		x := i % 10
-		y := i / 10
+		if evt.SysEx != nil {
+			continue
+		}
 		tap := launchpad.Tap{
-			Time:       time.Now(),
-			X:          x,
-			Y:          y,
-			Coordinate: launchpad.Coord(x, y),
+			Time: time.Now(),
+		}
+		i := int(evt.Data1)
+		switch evt.Status & 0xf0 {
+		case statusNoteOn:
+			// note on with a velocity of 0 is a note off
+			tap.Type = launchpad.Press
+			tap.Velocity = int(evt.Data2)
+			if evt.Data2 == 0 {
+				tap.Type = launchpad.Release
+			}
+		case statusControlChange:
+			// in programmer mode the top row and right column send CCs,
+			// 127 when pressed and 0 when lifted.
+			if !l.Profile.isButton(i) {
+				continue
+			}
+			tap.Type = launchpad.Press
+			tap.Velocity = int(evt.Data2)
+			if evt.Data2 == 0 {
+				tap.Type = launchpad.Release
+			}
+		case statusNoteOff:
+			tap.Type = launchpad.Release
+			tap.Velocity = int(evt.Data2)
+		case statusPolyAftertouch:
+			tap.Type = launchpad.Aftertouch
+			tap.Pressure = int(evt.Data2)
+		case statusChannelAftertouch:
+			// channel aftertouch is not tied to a pad
+			tap.Type = launchpad.Aftertouch
+			tap.Pressure = int(evt.Data1)
+			taps = append(taps, tap)
+			continue
+		default:
+			continue
 		}
+		tap.X = i % 10
+		tap.Y = i / 10
+		tap.Coordinate = launchpad.Coord(tap.X, tap.Y)
+		tap.Region = tap.Coordinate.Region()
 		taps = append(taps, tap)
 	}
 	return
 }
 
+// isButtonCC returns true if a CC number belongs to one of the round
+// buttons on the top row (91-98) or right column (19-89). The logo (99)
+// cannot be pressed.
+func isButtonCC(cc int) bool {
+	switch {
+	case cc >= 91 && cc <= 98:
+		return true
+	case cc >= 19 && cc <= 89 && cc%10 == 9:
+		return true
+	}
+	return false
+}
+
 // msg sends messages to the launchpad over the DAW interface, leaving MIDI open for use
 func (l *Launchpad) msg(function Function, args []byte) error {
-	err := l.DAW.outputStream.WriteSysExBytes(portmidi.Time(), msg(function, args))
+	err := l.write(func() error {
+		return l.DAW.WriteSysEx(l.Profile.msg(function, args))
+	})
 	time.Sleep(5 * time.Millisecond)
 	return err
 }
 
+// setting sends a message like msg, and keeps its arguments so that they
+// are sent again if the device is reconnected.
+func (l *Launchpad) setting(function Function, args []byte) error {
+	if err := l.msg(function, args); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.settings[function] = args
+	l.mu.Unlock()
+	return nil
+}
+
 // Colorspec creates a single light command for execution with LightSysEx
 func Colorspec(c launchpad.Coordinate, r, g, b int8) []byte {
 	var colorspec []byte
 	colorspec = append(colorspec, byte(c))
-	if r < 0 {
-		r = -r
-	}
-	if g < 0 {
-		g = -g
-	}
-	if b < 0 {
-		b = -b
-	}
-	rgb := []byte{byte(r), byte(g), byte(b)}
-	colorspec = append(colorspec, rgb...)
+	rgb := color.RGB(int(r), int(g), int(b))
+	colorspec = append(colorspec, rgb.R, rgb.G, rgb.B)
 	return colorspec
 }
-
-// msg builds SysEx messages into the appropriate format
-func msg(function Function, args []byte) []byte {
-	msg := sysExPrefix
-	msg = append(msg, byte(function))
-	msg = append(msg, args...)
-	msg = append(msg, sysExSuffix)
-	return msg
-}
@@ -0,0 +1,378 @@
+package lpx
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eriner/launchpad"
+)
+
+// newTestLaunchpad returns a Launchpad on Loopbacks, and the device ends
+// of its MIDI and DAW transports.
+func newTestLaunchpad(t *testing.T, opts ...Option) (lp *Launchpad, midi, daw *Loopback) {
+	t.Helper()
+	hostMIDI, midi := NewLoopback()
+	hostDAW, daw := NewLoopback()
+	lp = New(hostMIDI, hostDAW, opts...)
+	t.Cleanup(func() {
+		lp.Close()
+	})
+	return lp, midi, daw
+}
+
+// readSysEx returns the SysEx messages written to the device end of a
+// Loopback since the last call.
+func readSysEx(t *testing.T, dev *Loopback) [][]byte {
+	t.Helper()
+	evts, err := dev.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs [][]byte
+	for _, evt := range evts {
+		if evt.SysEx != nil {
+			msgs = append(msgs, evt.SysEx)
+		}
+	}
+	return msgs
+}
+
+func TestSettingsSysEx(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(*Launchpad) error
+		want []byte
+	}{
+		{
+			name: "LED feedback internal",
+			set:  func(l *Launchpad) error { return l.LEDFeedback(true, false) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x0a, 0x01, 0x00, 0xf7},
+		},
+		{
+			name: "LED feedback external",
+			set:  func(l *Launchpad) error { return l.LEDFeedback(false, true) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x0a, 0x00, 0x01, 0xf7},
+		},
+		{
+			name: "LED feedback off",
+			set:  func(l *Launchpad) error { return l.LEDFeedback(false, false) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x0a, 0x00, 0x00, 0xf7},
+		},
+		{
+			name: "brightness",
+			set:  func(l *Launchpad) error { return l.Brightness(0x40) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x08, 0x40, 0xf7},
+		},
+		{
+			name: "brightness above max",
+			set:  func(l *Launchpad) error { return l.Brightness(0xff) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x08, 0x7f, 0xf7},
+		},
+		{
+			name: "velocity curve",
+			set:  func(l *Launchpad) error { return l.Velocity(VelocityCurveHigh, 64) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x04, 0x02, 0x40, 0xf7},
+		},
+		{
+			name: "fixed velocity",
+			set:  func(l *Launchpad) error { return l.Velocity(VelocityCurveFixed, 100) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x04, 0x03, 0x64, 0xf7},
+		},
+		{
+			name: "fixed velocity below min",
+			set:  func(l *Launchpad) error { return l.Velocity(VelocityCurveFixed, 0) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x04, 0x03, 0x01, 0xf7},
+		},
+		{
+			name: "fixed velocity above max",
+			set:  func(l *Launchpad) error { return l.Velocity(VelocityCurveFixed, 0xff) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x04, 0x03, 0x7f, 0xf7},
+		},
+		{
+			name: "LED sleep",
+			set:  func(l *Launchpad) error { return l.LEDSleep(LEDAsleep) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x09, 0x00, 0xf7},
+		},
+		{
+			name: "LED wake",
+			set:  func(l *Launchpad) error { return l.LEDSleep(LEDAwake) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x09, 0x01, 0xf7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp, _, daw := newTestLaunchpad(t)
+			if err := tt.set(lp); err != nil {
+				t.Fatal(err)
+			}
+			msgs := readSysEx(t, daw)
+			if len(msgs) != 1 || !bytes.Equal(msgs[0], tt.want) {
+				t.Fatalf("got % x, want % x", msgs, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsSysExProfile(t *testing.T) {
+	lp, _, daw := newTestLaunchpad(t, WithProfile(&LaunchpadMiniMK3))
+	if err := lp.Brightness(0x10); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0d, 0x08, 0x10, 0xf7}
+	if msgs := readSysEx(t, daw); len(msgs) != 1 || !bytes.Equal(msgs[0], want) {
+		t.Fatalf("got % x, want % x", msgs, want)
+	}
+}
+
+func TestVelocityUnsupported(t *testing.T) {
+	lp, _, daw := newTestLaunchpad(t, WithProfile(&LaunchpadMiniMK3))
+	if err := lp.Velocity(VelocityCurveMedium, 64); err != ErrUnsupported {
+		t.Fatalf("Velocity: got %v, want ErrUnsupported", err)
+	}
+	if _, _, err := lp.CurrentVelocity(); err != ErrUnsupported {
+		t.Fatalf("CurrentVelocity: got %v, want ErrUnsupported", err)
+	}
+	if msgs := readSysEx(t, daw); len(msgs) != 0 {
+		t.Fatalf("sent % x to a model without velocity", msgs)
+	}
+}
+
+func TestReadDecoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int64
+		data1  int64
+		data2  int64
+		want   launchpad.Tap
+	}{
+		{
+			name:   "note on",
+			status: 0x90, data1: 11, data2: 100,
+			want: launchpad.Tap{Type: launchpad.Press, X: 1, Y: 1, Velocity: 100},
+		},
+		{
+			name:   "note on without velocity",
+			status: 0x90, data1: 88, data2: 0,
+			want: launchpad.Tap{Type: launchpad.Release, X: 8, Y: 8},
+		},
+		{
+			name:   "note off",
+			status: 0x80, data1: 45, data2: 64,
+			want: launchpad.Tap{Type: launchpad.Release, X: 5, Y: 4, Velocity: 64},
+		},
+		{
+			name:   "top row button pressed",
+			status: 0xb0, data1: 91, data2: 127,
+			want: launchpad.Tap{Type: launchpad.Press, X: 1, Y: 9, Velocity: 127},
+		},
+		{
+			name:   "right column button released",
+			status: 0xb0, data1: 19, data2: 0,
+			want: launchpad.Tap{Type: launchpad.Release, X: 9, Y: 1},
+		},
+		{
+			name:   "polyphonic aftertouch",
+			status: 0xa0, data1: 11, data2: 90,
+			want: launchpad.Tap{Type: launchpad.Aftertouch, X: 1, Y: 1, Pressure: 90},
+		},
+		{
+			name:   "channel aftertouch",
+			status: 0xd0, data1: 70,
+			want: launchpad.Tap{Type: launchpad.Aftertouch, Pressure: 70},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp, midi, _ := newTestLaunchpad(t)
+			// the logo is not a button, so it is skipped
+			midi.WriteShort(0xb0, 99, 127)
+			midi.WriteShort(tt.status, tt.data1, tt.data2)
+			var tap launchpad.Tap
+			select {
+			case tap = <-lp.Listen():
+			case <-time.After(time.Second):
+				t.Fatal("no tap was read")
+			}
+			if tap.Type != tt.want.Type || tap.X != tt.want.X || tap.Y != tt.want.Y ||
+				tap.Velocity != tt.want.Velocity || tap.Pressure != tt.want.Pressure {
+				t.Fatalf("got %+v, want %+v", tap, tt.want)
+			}
+			if tt.status != 0xd0 && tap.Coordinate != launchpad.Coord(tt.want.X, tt.want.Y) {
+				t.Fatalf("got coordinate %d for X: %d, Y: %d", tap.Coordinate, tap.X, tap.Y)
+			}
+		})
+	}
+}
+
+func TestLightRGBSysEx(t *testing.T) {
+	tests := []struct {
+		name  string
+		light launchpad.Light
+		want  []byte
+	}{
+		{
+			name:  "static",
+			light: launchpad.Light{Effect: launchpad.EffectStatic, Coord: 11, R: 127, G: 64, B: 0},
+			want:  []byte{0x03, 11, 127, 64, 0},
+		},
+		{
+			name:  "pulse",
+			light: launchpad.Light{Effect: launchpad.EffectPulse, Coord: 45, R: 127},
+			want:  []byte{0x02, 45, byte(Red)},
+		},
+		{
+			name:  "flash",
+			light: launchpad.Light{Effect: launchpad.EffectFlash, Coord: 88, Color: Red, FlashColor: White},
+			want:  []byte{0x01, 88, byte(Red), byte(White)},
+		},
+		{
+			name:  "off",
+			light: launchpad.Light{Effect: launchpad.EffectOff, Coord: 99, Color: Red},
+			want:  []byte{0x00, 99, byte(Black)},
+		},
+	}
+	var all []launchpad.Light
+	var spec []byte
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LightRGBSysEx(&tt.light); !bytes.Equal(got, tt.want) {
+				t.Fatalf("got % x, want % x", got, tt.want)
+			}
+		})
+		all = append(all, tt.light)
+		spec = append(spec, tt.want...)
+	}
+	if got := colorspecs(all); !bytes.Equal(got, spec) {
+		t.Fatalf("colorspecs: got % x, want % x", got, spec)
+	}
+
+	lp, _, daw := newTestLaunchpad(t)
+	if err := lp.LightSysEx(all); err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x03}, spec...)
+	want = append(want, 0xf7)
+	if msgs := readSysEx(t, daw); len(msgs) != 1 || !bytes.Equal(msgs[0], want) {
+		t.Fatalf("LightSysEx: got % x, want % x", msgs, want)
+	}
+	if n := lp.FrameSize(all); n != len(want) {
+		t.Fatalf("FrameSize: got %d, want %d", n, len(want))
+	}
+}
+
+// answer plays a device on the DAW end of a Loopback which answers each
+// SysEx message found in replies. It returns a function which lists every
+// SysEx message the device has been sent.
+func answer(t *testing.T, daw *Loopback, replies map[string][]byte) func() [][]byte {
+	t.Helper()
+	var mu sync.Mutex
+	var sent [][]byte
+	respond(t, daw, func(req []byte) [][]byte {
+		mu.Lock()
+		sent = append(sent, req)
+		mu.Unlock()
+		if r, ok := replies[string(req)]; ok {
+			return [][]byte{r}
+		}
+		return nil
+	})
+	return func() [][]byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][]byte(nil), sent...)
+	}
+}
+
+// versionResponse is the answer to a version inquiry, with a bootloader
+// version of 0123 and an application version of 0456.
+var versionResponse = []byte{0xf0, 0x00, 0x20, 0x29, 0x00, 0x70, 0, 1, 2, 3, 0, 4, 5, 6, 0xf7}
+
+func TestSetupProMK3(t *testing.T) {
+	p := &LaunchpadProMK3
+	lp, _, daw := newTestLaunchpad(t, WithProfile(p))
+	sent := answer(t, daw, map[string][]byte{
+		// the device was left in programmer mode
+		string(p.msg(FunctionProgramMode, nil)): p.msg(FunctionProgramMode, []byte{0x01}),
+		// the Pro MK3's own layout reply, which is not a Launchpad X layout
+		string(p.msg(FunctionLayout, nil)): p.msg(FunctionLayout, []byte{0x00, 0x00}),
+		string(msgDeviceInquiry): {
+			0xf0, 0x7e, 0x00, 0x06, 0x02, 0x00, 0x20, 0x29,
+			0x23, 0x01, 0x00, 0x00, 0x00, 0x04, 0x02, 0x01, 0xf7,
+		},
+		string(msgVersionInquiry): versionResponse,
+	})
+	if err := lp.setup(); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range sent() {
+		if bytes.Equal(m, p.msg(FunctionLayout, nil)) {
+			t.Fatal("the layout of a Pro MK3 was queried")
+		}
+	}
+	if pm, err := lp.CurrentProgramMode(); err != nil || pm != ProgramModeProgrammer {
+		t.Fatalf("got program mode %v, %v, want programmer mode", pm, err)
+	}
+	if _, err := lp.CurrentLayout(); err != ErrUnsupported {
+		t.Fatalf("CurrentLayout: got %v, want ErrUnsupported", err)
+	}
+	if err := lp.Light(launchpad.Light{Coord: launchpad.Coord(1, 1), Effect: launchpad.EffectStatic, Color: Red}); err != nil {
+		t.Fatalf("Light in programmer mode: %v", err)
+	}
+	if lp.Family != 0x0123 {
+		t.Fatalf("got family %#04x, want 0x0123", lp.Family)
+	}
+}
+
+func TestCurrentLEDFeedback(t *testing.T) {
+	p := &LaunchpadX
+	tests := []struct {
+		reply              []byte
+		internal, external bool
+	}{
+		{[]byte{0x00, 0x00}, false, false},
+		{[]byte{0x01, 0x00}, true, false},
+		{[]byte{0x00, 0x01}, false, true},
+		{[]byte{0x01, 0x01}, true, true},
+	}
+	for _, tt := range tests {
+		lp, _, daw := newTestLaunchpad(t)
+		answer(t, daw, map[string][]byte{
+			string(p.msg(FunctionLEDFeedback, nil)): p.msg(FunctionLEDFeedback, tt.reply),
+		})
+		internal, external, err := lp.CurrentLEDFeedback()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if internal != tt.internal || external != tt.external {
+			t.Errorf("reply % x: got %v, %v, want %v, %v", tt.reply, internal, external, tt.internal, tt.external)
+		}
+	}
+}
+
+func TestCurrentVelocity(t *testing.T) {
+	p := &LaunchpadX
+	tests := []struct {
+		reply []byte
+		curve VelocityCurve
+		fixed byte
+	}{
+		{[]byte{0x00, 0x7f}, VelocityCurveLow, 127},
+		{[]byte{0x01, 0x40}, VelocityCurveMedium, 64},
+		{[]byte{0x03, 0x01}, VelocityCurveFixed, 1},
+	}
+	for _, tt := range tests {
+		lp, _, daw := newTestLaunchpad(t)
+		answer(t, daw, map[string][]byte{
+			string(p.msg(FunctionVelocity, nil)): p.msg(FunctionVelocity, tt.reply),
+		})
+		curve, fixed, err := lp.CurrentVelocity()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if curve != tt.curve || fixed != tt.fixed {
+			t.Errorf("reply % x: got %v, %d, want %v, %d", tt.reply, curve, fixed, tt.curve, tt.fixed)
+		}
+	}
+}
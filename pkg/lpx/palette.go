@@ -0,0 +1,120 @@
+package lpx
+
+import (
+	"math"
+
+	"github.com/eriner/launchpad"
+)
+
+// Palette is the Launchpad X's default 128 color palette, used for the
+// pulse and flash effects, as 0-255 RGB values.
+var Palette = [128][3]uint8{
+	{0x00, 0x00, 0x00}, {0x1e, 0x1e, 0x1e}, {0x7f, 0x7f, 0x7f}, {0xff, 0xff, 0xff}, // 0-3
+	{0xff, 0x4c, 0x4c}, {0xff, 0x00, 0x00}, {0x59, 0x00, 0x00}, {0x19, 0x00, 0x00}, // 4-7
+	{0xff, 0xbd, 0x6c}, {0xff, 0x54, 0x00}, {0x59, 0x1d, 0x00}, {0x27, 0x1b, 0x00}, // 8-11
+	{0xff, 0xff, 0x4c}, {0xff, 0xff, 0x00}, {0x59, 0x59, 0x00}, {0x19, 0x19, 0x00}, // 12-15
+	{0x88, 0xff, 0x4c}, {0x54, 0xff, 0x00}, {0x1d, 0x59, 0x00}, {0x14, 0x2b, 0x00}, // 16-19
+	{0x4c, 0xff, 0x4c}, {0x00, 0xff, 0x00}, {0x00, 0x59, 0x00}, {0x00, 0x19, 0x00}, // 20-23
+	{0x4c, 0xff, 0x5e}, {0x00, 0xff, 0x19}, {0x00, 0x59, 0x0d}, {0x00, 0x19, 0x02}, // 24-27
+	{0x4c, 0xff, 0x88}, {0x00, 0xff, 0x55}, {0x00, 0x59, 0x1d}, {0x00, 0x1f, 0x12}, // 28-31
+	{0x4c, 0xff, 0xb7}, {0x00, 0xff, 0x99}, {0x00, 0x59, 0x35}, {0x00, 0x19, 0x12}, // 32-35
+	{0x4c, 0xc3, 0xff}, {0x00, 0xa9, 0xff}, {0x00, 0x41, 0x52}, {0x00, 0x10, 0x19}, // 36-39
+	{0x4c, 0x88, 0xff}, {0x00, 0x55, 0xff}, {0x00, 0x1d, 0x59}, {0x00, 0x08, 0x19}, // 40-43
+	{0x4c, 0x4c, 0xff}, {0x00, 0x00, 0xff}, {0x00, 0x00, 0x59}, {0x00, 0x00, 0x19}, // 44-47
+	{0x87, 0x4c, 0xff}, {0x54, 0x00, 0xff}, {0x19, 0x00, 0x64}, {0x0f, 0x00, 0x30}, // 48-51
+	{0xff, 0x4c, 0xff}, {0xff, 0x00, 0xff}, {0x59, 0x00, 0x59}, {0x19, 0x00, 0x19}, // 52-55
+	{0xff, 0x4c, 0x87}, {0xff, 0x00, 0x54}, {0x59, 0x00, 0x1d}, {0x22, 0x00, 0x13}, // 56-59
+	{0xff, 0x15, 0x00}, {0x99, 0x35, 0x00}, {0x79, 0x51, 0x00}, {0x43, 0x64, 0x00}, // 60-63
+	{0x03, 0x39, 0x00}, {0x00, 0x57, 0x35}, {0x00, 0x54, 0x7f}, {0x00, 0x00, 0xff}, // 64-67
+	{0x00, 0x45, 0x4f}, {0x25, 0x00, 0xcc}, {0x7f, 0x7f, 0x7f}, {0x20, 0x20, 0x20}, // 68-71
+	{0xff, 0x00, 0x00}, {0xbd, 0xff, 0x2d}, {0xaf, 0xed, 0x06}, {0x64, 0xff, 0x09}, // 72-75
+	{0x10, 0x8b, 0x00}, {0x00, 0xff, 0x87}, {0x00, 0xa9, 0xff}, {0x00, 0x2a, 0xff}, // 76-79
+	{0x3f, 0x00, 0xff}, {0x7a, 0x00, 0xff}, {0xb2, 0x1a, 0x7d}, {0x40, 0x21, 0x00}, // 80-83
+	{0xff, 0x4a, 0x00}, {0x88, 0xe1, 0x06}, {0x72, 0xff, 0x15}, {0x00, 0xff, 0x00}, // 84-87
+	{0x3b, 0xff, 0x26}, {0x59, 0xff, 0x71}, {0x38, 0xff, 0xcc}, {0x5b, 0x8a, 0xff}, // 88-91
+	{0x31, 0x51, 0xc6}, {0x87, 0x7f, 0xe9}, {0xd3, 0x1d, 0xff}, {0xff, 0x00, 0x5d}, // 92-95
+	{0xff, 0x7f, 0x00}, {0xb9, 0xb0, 0x00}, {0x90, 0xff, 0x00}, {0x83, 0x5d, 0x07}, // 96-99
+	{0x39, 0x2b, 0x00}, {0x14, 0x4c, 0x10}, {0x0d, 0x50, 0x38}, {0x15, 0x15, 0x2a}, // 100-103
+	{0x16, 0x20, 0x5a}, {0x69, 0x3c, 0x1c}, {0xa8, 0x00, 0x0a}, {0xde, 0x51, 0x3d}, // 104-107
+	{0xd8, 0x6a, 0x1c}, {0xff, 0xe1, 0x26}, {0x9e, 0xe1, 0x2f}, {0x67, 0xb5, 0x0f}, // 108-111
+	{0x1e, 0x1e, 0x30}, {0xdc, 0xff, 0x6b}, {0x80, 0xff, 0xbd}, {0x9a, 0x99, 0xff}, // 112-115
+	{0x8e, 0x66, 0xff}, {0x40, 0x40, 0x40}, {0x75, 0x75, 0x75}, {0xe0, 0xff, 0xff}, // 116-119
+	{0xa0, 0x00, 0x00}, {0x35, 0x00, 0x00}, {0x1a, 0xd0, 0x00}, {0x07, 0x42, 0x00}, // 120-123
+	{0xb9, 0xb0, 0x00}, {0x3f, 0x31, 0x00}, {0xb3, 0x5f, 0x00}, {0x4b, 0x15, 0x02}, // 124-127
+}
+
+// paletteLab is Palette in CIELAB, for nearest color searches
+var paletteLab = func() (out [128]lab) {
+	for i, c := range Palette {
+		out[i] = toLab(c[0], c[1], c[2])
+	}
+	return
+}()
+
+// PaletteRGB returns the RGB value of a palette color in the device's
+// 0-127 range, as used by launchpad.Light.
+func PaletteRGB(c launchpad.LightColor) (r, g, b int8) {
+	rgb := Palette[c&0x7f]
+	return int8(rgb[0] >> 1), int8(rgb[1] >> 1), int8(rgb[2] >> 1)
+}
+
+// PaletteColor returns the palette color perceptually closest to an RGB
+// value in the device's 0-127 range. Colors are compared in CIELAB.
+func PaletteColor(r, g, b int8) launchpad.LightColor {
+	want := toLab(scale127(r), scale127(g), scale127(b))
+	best := 0
+	bestDist := math.Inf(1)
+	for i, c := range paletteLab {
+		if d := want.dist(c); d < bestDist {
+			best = i
+			bestDist = d
+		}
+	}
+	return launchpad.LightColor(best)
+}
+
+// scale127 converts a 0-127 device value into 0-255
+func scale127(v int8) uint8 {
+	if v < 0 {
+		v = 0
+	}
+	return uint8(int(v) * 255 / 127)
+}
+
+// lab is a color in the CIELAB color space
+type lab struct {
+	l, a, b float64
+}
+
+// dist returns the squared CIE76 color difference between two colors
+func (c lab) dist(o lab) float64 {
+	dl, da, db := c.l-o.l, c.a-o.a, c.b-o.b
+	return dl*dl + da*da + db*db
+}
+
+// toLab converts 0-255 sRGB into CIELAB with a D65 white point
+func toLab(r, g, b uint8) lab {
+	lin := func(v uint8) float64 {
+		c := float64(v) / 255
+		if c <= 0.04045 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	rl, gl, bl := lin(r), lin(g), lin(b)
+	x := (rl*0.4124 + gl*0.3576 + bl*0.1805) / 0.95047
+	y := (rl*0.2126 + gl*0.7152 + bl*0.0722) / 1.00000
+	z := (rl*0.0193 + gl*0.1192 + bl*0.9505) / 1.08883
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return lab{
+		l: 116*fy - 16,
+		a: 500 * (fx - fy),
+		b: 200 * (fy - fz),
+	}
+}
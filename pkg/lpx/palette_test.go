@@ -0,0 +1,71 @@
+package lpx
+
+import (
+	"testing"
+
+	"github.com/eriner/launchpad"
+)
+
+func TestPaletteRGB(t *testing.T) {
+	tests := []struct {
+		c       launchpad.LightColor
+		r, g, b int8
+	}{
+		{0, 0, 0, 0},
+		{3, 127, 127, 127},
+		{5, 127, 0, 0},
+		{9, 127, 42, 0},
+		{13, 127, 127, 0},
+		{21, 0, 127, 0},
+		{45, 0, 0, 127},
+		{53, 127, 0, 127},
+		{127, 37, 10, 1},
+		// only the 7 bits of a palette index are used
+		{128 + 5, 127, 0, 0},
+		{-1, 37, 10, 1},
+	}
+	for _, tt := range tests {
+		if r, g, b := PaletteRGB(tt.c); r != tt.r || g != tt.g || b != tt.b {
+			t.Errorf("PaletteRGB(%d) = %d, %d, %d, want %d, %d, %d", tt.c, r, g, b, tt.r, tt.g, tt.b)
+		}
+	}
+}
+
+func TestPaletteColor(t *testing.T) {
+	tests := []struct {
+		name    string
+		r, g, b int8
+		want    launchpad.LightColor
+	}{
+		{"black", 0, 0, 0, 0},
+		{"white", 127, 127, 127, 3},
+		{"red", 127, 0, 0, 5},
+		{"yellow", 127, 127, 0, 13},
+		{"green", 0, 127, 0, 21},
+		{"blue", 0, 0, 127, 45},
+		{"magenta", 127, 0, 127, 53},
+		{"near red", 127, 2, 2, 5},
+		{"negative", -1, -128, -5, 0},
+		{"negative channels are off", -127, 127, -1, 21},
+	}
+	for _, tt := range tests {
+		if got := PaletteColor(tt.r, tt.g, tt.b); got != tt.want {
+			t.Errorf("%s: PaletteColor(%d, %d, %d) = %d, want %d", tt.name, tt.r, tt.g, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPaletteRoundTrip(t *testing.T) {
+	// every palette color comes back as itself, or as a color which only
+	// differs by the bit lost scaling it into the device's range
+	near := func(a, b int8) bool {
+		return a-b <= 1 && b-a <= 1
+	}
+	for c := launchpad.LightColor(0); c < 128; c++ {
+		r, g, b := PaletteRGB(c)
+		got := PaletteColor(r, g, b)
+		if gr, gg, gb := PaletteRGB(got); !near(gr, r) || !near(gg, g) || !near(gb, b) {
+			t.Errorf("PaletteColor(PaletteRGB(%d)) = %d, which is %d, %d, %d, want %d, %d, %d", c, got, gr, gg, gb, r, g, b)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+//go:build !linux || (cgo && !lpx_alsa)
+
+package lpx
+
+import (
+	"sync"
+
+	"github.com/rakyll/portmidi"
+)
+
+// portmidiTransport is a Transport over a pair of PortMidi streams. PortMidi
+// is the default backend, except on Linux when built without cgo or with
+// the lpx_alsa tag.
+type portmidiTransport struct {
+	in  *portmidi.Stream
+	out *portmidi.Stream
+	// reader reassembles SysEx messages from in
+	reader sysExReader
+}
+
+// streams counts the open PortMidi transports. PortMidi only finds
+// devices plugged in after it started when it is re-initialized, which
+// would break every stream it has open, so that is only done when no
+// streams are open.
+//
+// This means that while another device is open, a device which is unplugged
+// is not found again when it is plugged back in, and stays disconnected
+// until every other device is closed. Programs which drive several devices
+// and need them to reconnect should use the ALSA backend on Linux, with
+// the lpx_alsa build tag.
+var streams struct {
+	sync.Mutex
+	n int
+}
+
+// openPort opens a Transport on a PortMidi input and output.
+func openPort(input, output int) (Transport, error) {
+	in, err := portmidi.NewInputStream(portmidi.DeviceID(input), 1024)
+	if err != nil {
+		return nil, err
+	}
+	out, err := portmidi.NewOutputStream(portmidi.DeviceID(output), 1024, 0)
+	if err != nil {
+		in.Close()
+		return nil, err
+	}
+	streams.Lock()
+	streams.n++
+	streams.Unlock()
+	return &portmidiTransport{in: in, out: out}, nil
+}
+
+func (t *portmidiTransport) WriteShort(status, data1, data2 int64) error {
+	return t.out.WriteShort(status, data1, data2)
+}
+
+func (t *portmidiTransport) WriteSysEx(msg []byte) error {
+	return t.out.WriteSysExBytes(portmidi.Time(), msg)
+}
+
+// Read reads the input once. PortMidi delivers every message four bytes
+// per event, so SysEx messages are reassembled by the reader.
+func (t *portmidiTransport) Read() ([]Event, error) {
+	b, err := t.in.ReadSysExBytes(64)
+	if err != nil {
+		return nil, err
+	}
+	msgs := t.reader.feed(b)
+	events := t.reader.shortEvents()
+	for _, m := range msgs {
+		events = append(events, Event{SysEx: m})
+	}
+	return events, nil
+}
+
+func (t *portmidiTransport) Close() error {
+	streams.Lock()
+	streams.n--
+	streams.Unlock()
+	inErr := t.in.Close()
+	if err := t.out.Close(); err != nil {
+		return err
+	}
+	return inErr
+}
+
+// listPorts lists the ports PortMidi knows of. If no PortMidi streams
+// are open, PortMidi is re-initialized first so that devices plugged in
+// since it started are found.
+func listPorts() ([]portInfo, error) {
+	streams.Lock()
+	if streams.n == 0 {
+		// counting the devices initializes it again
+		portmidi.Terminate()
+	}
+	streams.Unlock()
+	var ports []portInfo
+	for i := 0; i < portmidi.CountDevices(); i++ {
+		info := portmidi.Info(portmidi.DeviceID(i))
+		if info == nil {
+			continue
+		}
+		ports = append(ports, portInfo{
+			id:     i,
+			name:   info.Name,
+			input:  info.IsInputAvailable,
+			output: info.IsOutputAvailable,
+		})
+	}
+	return ports, nil
+}
+
+// openDevice opens the MIDI and DAW transports of a device.
+func openDevice(dev Device) (midi, daw Transport, err error) {
+	if midi, err = openPort(dev.midiIn, dev.midiOut); err != nil {
+		return nil, nil, err
+	}
+	if daw, err = openPort(dev.dawIn, dev.dawOut); err != nil {
+		midi.Close()
+		return nil, nil, err
+	}
+	return midi, daw, nil
+}
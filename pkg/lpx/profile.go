@@ -0,0 +1,152 @@
+package lpx
+
+import (
+	"github.com/eriner/launchpad"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUnsupported = errors.New("launchpad: not supported by this model")
+)
+
+// Profile describes a model of Launchpad which speaks the Launchpad X's
+// programmer protocol. The Mini MK3 and Pro MK3 use the same SysEx
+// messages, addressed with their own device ID, and the same note and CC
+// numbers for the pads and buttons they share with the Launchpad X.
+type Profile struct {
+	Name string
+	// DeviceID is the byte which follows Novation's ID in SysEx messages
+	DeviceID byte
+	// Family is the family code the model reports to a device inquiry in
+	// application mode, and BootFamily in bootloader mode. The codes of
+	// different models overlap, so they are only compared with the codes
+	// of the model which was opened.
+	Family     uint16
+	BootFamily uint16
+	// MIDIPort and DAWPort are the parts of a port's name which tell the
+	// model's MIDI and DAW interfaces apart. The rest of the name is shared
+	// by both interfaces of a unit.
+	MIDIPort string
+	DAWPort  string
+	// Layouts are the layouts which may be selected with Layout
+	Layouts []Layout
+	// LiveLayout is the layout the model switches to when Live mode is
+	// selected in Standalone mode. In DAW mode it is always Session.
+	LiveLayout Layout
+	// Velocity and Aftertouch are true if the pads are velocity and
+	// pressure sensitive, and the model can be configured with Velocity
+	// and Aftertouch.
+	Velocity   bool
+	Aftertouch bool
+	// ExtraButtons are the round buttons the model has besides the top
+	// row and right column of the Launchpad X. Their Region is RegionExtra.
+	ExtraButtons []launchpad.Coordinate
+}
+
+var (
+	LaunchpadX = Profile{
+		Name:       "Launchpad X",
+		DeviceID:   0x0c,
+		Family:     0x0103,
+		BootFamily: 0x0113,
+		MIDIPort:   "LPX MIDI",
+		DAWPort:    "LPX DAW",
+		Layouts: []Layout{
+			LayoutSession, LayoutNote,
+			LayoutCustom1, LayoutCustom2, LayoutCustom3, LayoutCutsom4,
+			LayoutDAWFaders, LayoutProgrammer,
+		},
+		LiveLayout: LayoutNote,
+		Velocity:   true,
+		Aftertouch: true,
+	}
+
+	// LaunchpadMiniMK3 has the same pads and buttons as the Launchpad X,
+	// but no Note layout and no velocity or pressure sensitivity.
+	LaunchpadMiniMK3 = Profile{
+		Name:       "Launchpad Mini MK3",
+		DeviceID:   0x0d,
+		Family:     0x0113,
+		BootFamily: 0x0117,
+		MIDIPort:   "LPMiniMK3 MIDI",
+		DAWPort:    "LPMiniMK3 DAW",
+		Layouts: []Layout{
+			LayoutSession,
+			LayoutCustom1, LayoutCustom2, LayoutCustom3,
+			LayoutDAWFaders, LayoutProgrammer,
+		},
+		LiveLayout: LayoutSession,
+	}
+
+	// LaunchpadProMK3 adds a left column of buttons, Shift above it, and
+	// two rows of buttons below the grid. Its layout message takes more
+	// arguments than the Launchpad X's, so no layouts may be selected with
+	// Layout. Programmer mode is selected with ProgramMode.
+	LaunchpadProMK3 = Profile{
+		Name:         "Launchpad Pro MK3",
+		DeviceID:     0x0e,
+		Family:       0x0123,
+		BootFamily:   0x0121,
+		MIDIPort:     "LPProMK3 MIDI",
+		DAWPort:      "LPProMK3 DAW",
+		LiveLayout:   LayoutSession,
+		Velocity:     true,
+		Aftertouch:   true,
+		ExtraButtons: proButtons(),
+	}
+
+	// Profiles are the models found by Devices
+	Profiles = []*Profile{&LaunchpadX, &LaunchpadMiniMK3, &LaunchpadProMK3}
+)
+
+// proButtons are the Pro MK3 buttons which the Launchpad X lacks. Their
+// CC numbers are their coordinates: the left column is X 0, Y 1-8, with
+// Shift at Y 9, and the rows below the grid are Y 0 and Y 10, X 1-8.
+func proButtons() (buttons []launchpad.Coordinate) {
+	for i := 1; i <= 8; i++ {
+		buttons = append(buttons,
+			launchpad.Coord(0, i),
+			launchpad.Coord(i, 0),
+			launchpad.Coord(i, 10),
+		)
+	}
+	return append(buttons, launchpad.Coord(0, 9))
+}
+
+// prefix is the start of every SysEx message to the model
+func (p *Profile) prefix() []byte {
+	return []byte{0xf0, 0x00, 0x20, 0x29, 0x02, p.DeviceID}
+}
+
+// msg builds SysEx messages into the appropriate format
+func (p *Profile) msg(function Function, args []byte) []byte {
+	msg := p.prefix()
+	msg = append(msg, byte(function))
+	msg = append(msg, args...)
+	msg = append(msg, sysExSuffix)
+	return msg
+}
+
+// HasLayout returns true if lay may be selected on the model.
+func (p *Profile) HasLayout(lay Layout) bool {
+	for _, l := range p.Layouts {
+		if l == lay {
+			return true
+		}
+	}
+	return false
+}
+
+// isButton returns true if a CC number belongs to one of the model's
+// round buttons.
+func (p *Profile) isButton(cc int) bool {
+	if isButtonCC(cc) {
+		return true
+	}
+	for _, c := range p.ExtraButtons {
+		if int(c) == cc {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package lpx
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// query sends a command without arguments, which the device answers with
+// the same command carrying its current n argument bytes.
+func (l *Launchpad) query(f Function, n int) ([]byte, error) {
+	/*
+		Host => Launchpad X:
+		Hex: F0h 00h 20h 29h 02h 0Ch <function> F7h
+		Launchpad X => Host:
+		Hex: F0h 00h 20h 29h 02h 0Ch <function> <value> [<value> [...]] F7h
+	*/
+	prefix := append(l.Profile.prefix(), byte(f))
+	resp, err := l.request(l.Profile.msg(f, nil), func(m []byte) bool {
+		return len(m) >= len(prefix)+n+1 && bytes.HasPrefix(m, prefix)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "launchpad: querying function %#02x", byte(f))
+	}
+	return resp[len(prefix) : len(prefix)+n], nil
+}
+
+// CurrentMode asks the device whether it is in Standalone or DAW mode.
+func (l *Launchpad) CurrentMode() (DeviceMode, error) {
+	args, err := l.query(FunctionMode, 1)
+	if err != nil {
+		return 0, err
+	}
+	m := DeviceMode(args[0])
+	l.mu.Lock()
+	l.mode = m
+	l.mu.Unlock()
+	return m, nil
+}
+
+// CurrentLayout asks the device for its selected layout. Models without
+// layouts in their Profile return ErrUnsupported.
+func (l *Launchpad) CurrentLayout() (Layout, error) {
+	if len(l.Profile.Layouts) == 0 {
+		return 0, ErrUnsupported
+	}
+	args, err := l.query(FunctionLayout, 1)
+	if err != nil {
+		return 0, err
+	}
+	lay := Layout(args[0])
+	l.mu.Lock()
+	l.setLayout(lay)
+	l.mu.Unlock()
+	return lay, nil
+}
+
+// CurrentProgramMode asks the device whether it is in Live or Programmer mode.
+func (l *Launchpad) CurrentProgramMode() (ProgramMode, error) {
+	args, err := l.query(FunctionProgramMode, 1)
+	if err != nil {
+		return 0, err
+	}
+	pm := ProgramMode(args[0])
+	l.mu.Lock()
+	l.setProgramMode(pm)
+	l.mu.Unlock()
+	return pm, nil
+}
+
+// CurrentAftertouch asks the device for its aftertouch type and threshold.
+func (l *Launchpad) CurrentAftertouch() (AftertouchType, AftertouchThreshold, error) {
+	if !l.Profile.Aftertouch {
+		return 0, 0, ErrUnsupported
+	}
+	args, err := l.query(FunctionAftertouch, 2)
+	if err != nil {
+		return 0, 0, err
+	}
+	return AftertouchType(args[0]), AftertouchThreshold(args[1]), nil
+}
+
+// CurrentVelocity asks the device for its velocity curve and fixed velocity.
+func (l *Launchpad) CurrentVelocity() (VelocityCurve, byte, error) {
+	if !l.Profile.Velocity {
+		return 0, 0, ErrUnsupported
+	}
+	args, err := l.query(FunctionVelocity, 2)
+	if err != nil {
+		return 0, 0, err
+	}
+	return VelocityCurve(args[0]), args[1], nil
+}
+
+// CurrentLEDFeedback asks the device for its LED feedback settings.
+func (l *Launchpad) CurrentLEDFeedback() (internal, external bool, err error) {
+	args, err := l.query(FunctionLEDFeedback, 2)
+	if err != nil {
+		return false, false, err
+	}
+	return args[0] == 0x01, args[1] == 0x01, nil
+}
+
+// CurrentBrightness asks the device for its LED brightness.
+func (l *Launchpad) CurrentBrightness() (Brightness, error) {
+	args, err := l.query(FunctionBrightness, 1)
+	if err != nil {
+		return 0, err
+	}
+	return Brightness(args[0]), nil
+}
+
+// CurrentLEDSleep asks the device whether its LEDs are asleep.
+func (l *Launchpad) CurrentLEDSleep() (LEDSleep, error) {
+	args, err := l.query(FunctionLEDSleep, 1)
+	if err != nil {
+		return 0, err
+	}
+	return LEDSleep(args[0]), nil
+}
+
+// setLayout updates the cached layout. Selecting the programmer layout is
+// the same as selecting programmer mode. l.mu must be held.
+func (l *Launchpad) setLayout(lay Layout) {
+	l.layout = lay
+	if lay == LayoutProgrammer {
+		l.programMode = ProgramModeProgrammer
+	} else {
+		l.programMode = ProgramModeLive
+	}
+}
+
+// setProgramMode updates the cached program mode. l.mu must be held.
+//
+// Ref: When selecting Live mode with this message, Launchpad X switches
+// to Session layout, or Note mode when not in DAW mode. Other models
+// switch to their Profile's LiveLayout when not in DAW mode.
+func (l *Launchpad) setProgramMode(pm ProgramMode) {
+	l.programMode = pm
+	switch {
+	case pm == ProgramModeProgrammer:
+		l.layout = LayoutProgrammer
+	case l.mode == ModeDAW:
+		l.layout = LayoutSession
+	default:
+		l.layout = l.Profile.LiveLayout
+	}
+}
+
+// requireProgrammer returns ErrWrongMode unless the device is in programmer mode.
+func (l *Launchpad) requireProgrammer() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.programMode != ProgramModeProgrammer {
+		return ErrWrongMode
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package lpx
+
+// sysExReader reassembles SysEx messages from an input stream.
+//
+// PortMidi delivers SysEx messages four bytes per event, and a long message
+// may be split across several reads. Real-time messages can be interleaved
+// with a SysEx message, each in an event of its own.
+type sysExReader struct {
+	// buf holds the SysEx message being reassembled
+	buf []byte
+	// inSysEx is true while a SysEx message has been started but not ended
+	inSysEx bool
+	// events holds the short messages seen between SysEx messages until
+	// they are taken with shortEvents
+	events []Event
+}
+
+// feed parses raw event bytes, four per event, and returns every complete
+// SysEx message, including the 0xf0 and 0xf7 framing bytes.
+func (r *sysExReader) feed(b []byte) (msgs [][]byte) {
+	for i := 0; i < len(b); i += 4 {
+		end := i + 4
+		if end > len(b) {
+			end = len(b)
+		}
+		event := b[i:end]
+		switch {
+		case event[0] >= 0xf8:
+			// real-time messages have an event to themselves
+			continue
+		case event[0] == 0xf0:
+			r.buf = r.buf[:0]
+			r.inSysEx = true
+		case !r.inSysEx:
+			// a short message, which is kept for shortEvents
+			if event[0]&0x80 != 0 && len(event) >= 3 {
+				r.events = append(r.events, Event{
+					Status: int64(event[0]),
+					Data1:  int64(event[1]),
+					Data2:  int64(event[2]),
+				})
+			}
+			continue
+		}
+		for _, c := range event {
+			if c&0x80 != 0 && c != 0xf0 && c != 0xf7 {
+				// any other status byte aborts the message
+				r.inSysEx = false
+				break
+			}
+			r.buf = append(r.buf, c)
+			if c == 0xf7 {
+				m := make([]byte, len(r.buf))
+				copy(m, r.buf)
+				msgs = append(msgs, m)
+				r.inSysEx = false
+				break
+			}
+		}
+	}
+	return
+}
+
+// shortEvents returns and forgets the short messages seen by feed.
+func (r *sysExReader) shortEvents() []Event {
+	events := r.events
+	r.events = nil
+	return events
+}
@@ -0,0 +1,111 @@
+package lpx
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// inquiryResponse is a Launchpad X's answer to a device inquiry, which is
+// 17 bytes long and so split across five PortMidi events.
+var inquiryResponse = []byte{
+	0xf0, 0x7e, 0x00, 0x06, 0x02, 0x00, 0x20, 0x29,
+	0x03, 0x01, 0x00, 0x00, 0x00, 0x04, 0x02, 0x01, 0xf7,
+}
+
+// events splits a message into PortMidi events, four bytes each, padding
+// the last with zeros.
+func events(m []byte) []byte {
+	b := append([]byte(nil), m...)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestSysExReaderFeed(t *testing.T) {
+	tests := []struct {
+		name  string
+		feeds [][]byte
+		want  [][]byte
+		short []Event
+	}{
+		{
+			name:  "whole message",
+			feeds: [][]byte{events(inquiryResponse)},
+			want:  [][]byte{inquiryResponse},
+		},
+		{
+			name: "split across reads",
+			feeds: [][]byte{
+				events(inquiryResponse)[:8],
+				events(inquiryResponse)[8:],
+			},
+			want: [][]byte{inquiryResponse},
+		},
+		{
+			name: "real-time inside",
+			feeds: [][]byte{
+				events(inquiryResponse)[:8],
+				{0xf8, 0, 0, 0},
+				events(inquiryResponse)[8:],
+			},
+			want: [][]byte{inquiryResponse},
+		},
+		{
+			name: "short messages around",
+			feeds: [][]byte{
+				append([]byte{0x90, 0x33, 0x7f, 0}, events(inquiryResponse)...),
+				{0xb0, 0x5b, 0x7f, 0},
+			},
+			want: [][]byte{inquiryResponse},
+			short: []Event{
+				{Status: 0x90, Data1: 0x33, Data2: 0x7f},
+				{Status: 0xb0, Data1: 0x5b, Data2: 0x7f},
+			},
+		},
+		{
+			name: "two messages",
+			feeds: [][]byte{
+				append(events(inquiryResponse), events([]byte{0xf0, 0x00, 0x20, 0xf7})...),
+			},
+			want: [][]byte{inquiryResponse, {0xf0, 0x00, 0x20, 0xf7}},
+		},
+		{
+			name: "aborted by a status byte",
+			feeds: [][]byte{
+				events(inquiryResponse)[:8],
+				{0x90, 0x33, 0x7f, 0},
+				events([]byte{0xf0, 0x7e, 0xf7}),
+			},
+			want: [][]byte{{0xf0, 0x7e, 0xf7}},
+		},
+		{
+			name:  "unterminated",
+			feeds: [][]byte{events(inquiryResponse)[:8]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r sysExReader
+			var got [][]byte
+			for _, b := range tt.feeds {
+				got = append(got, r.feed(b)...)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got % x, want % x", got, tt.want)
+			}
+			for i := range got {
+				if !bytes.Equal(got[i], tt.want[i]) {
+					t.Fatalf("message %d: got % x, want % x", i, got[i], tt.want[i])
+				}
+			}
+			if short := r.shortEvents(); !reflect.DeepEqual(short, tt.short) {
+				t.Fatalf("short events: got %+v, want %+v", short, tt.short)
+			}
+			if short := r.shortEvents(); short != nil {
+				t.Fatalf("short events not forgotten: %+v", short)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package lpx
+
+import (
+	"github.com/eriner/launchpad/pkg/color"
+)
+
+// TextScroll scrolls text across the device using its built-in font.
+// speed is in pads per second, 1-127, and loop repeats the text until
+// StopTextScroll is called. Only printable ASCII characters are shown.
+func (l *Launchpad) TextScroll(text string, c color.Color, speed int, loop bool) error {
+	/*
+		Host => Launchpad X:
+		Hex: F0h 00h 20h 29h 02h 0Ch 07h <loop> <speed> <colourspec> [<text>] F7h
+		The <colourspec> is either:
+			- 0: Palette colour, 1 byte specifying palette entry.
+			- 1: RGB colour, 3 bytes for Red, Green and Blue (127: Max, 0: Min).
+		Sending the message without any arguments stops the text.
+	*/
+	if speed < 1 {
+		speed = 1
+	}
+	if speed > 127 {
+		speed = 127
+	}
+	var args []byte
+	if loop {
+		args = append(args, 0x01)
+	} else {
+		args = append(args, 0x00)
+	}
+	args = append(args, byte(speed))
+	args = append(args, 0x01, c.R, c.G, c.B)
+	for _, r := range text {
+		if r >= 0x20 && r < 0x7f {
+			args = append(args, byte(r))
+		}
+	}
+	return l.msg(FunctionTextScroll, args)
+}
+
+// StopTextScroll stops scrolling text started by TextScroll.
+func (l *Launchpad) StopTextScroll() error {
+	return l.msg(FunctionTextScroll, nil)
+}
@@ -0,0 +1,59 @@
+package lpx
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/eriner/launchpad/pkg/color"
+)
+
+func TestTextScrollSysEx(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(*Launchpad) error
+		want []byte
+	}{
+		{
+			name: "once",
+			set:  func(l *Launchpad) error { return l.TextScroll("Hi", color.RGB(127, 0, 64), 10, false) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x07, 0x00, 0x0a, 0x01, 0x7f, 0x00, 0x40, 'H', 'i', 0xf7},
+		},
+		{
+			name: "loop",
+			set:  func(l *Launchpad) error { return l.TextScroll("A", color.RGB(0, 127, 0), 20, true) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x07, 0x01, 0x14, 0x01, 0x00, 0x7f, 0x00, 'A', 0xf7},
+		},
+		{
+			name: "speed below min",
+			set:  func(l *Launchpad) error { return l.TextScroll("A", color.RGB(0, 0, 0), 0, false) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x07, 0x00, 0x01, 0x01, 0x00, 0x00, 0x00, 'A', 0xf7},
+		},
+		{
+			name: "speed above max",
+			set:  func(l *Launchpad) error { return l.TextScroll("A", color.RGB(0, 0, 0), 200, false) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x07, 0x00, 0x7f, 0x01, 0x00, 0x00, 0x00, 'A', 0xf7},
+		},
+		{
+			name: "unprintable characters dropped",
+			set:  func(l *Launchpad) error { return l.TextScroll("a\tb\x80é", color.RGB(0, 0, 0), 1, false) },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x07, 0x00, 0x01, 0x01, 0x00, 0x00, 0x00, 'a', 'b', 0xf7},
+		},
+		{
+			name: "stop",
+			set:  func(l *Launchpad) error { return l.StopTextScroll() },
+			want: []byte{0xf0, 0x00, 0x20, 0x29, 0x02, 0x0c, 0x07, 0xf7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp, _, daw := newTestLaunchpad(t)
+			if err := tt.set(lp); err != nil {
+				t.Fatal(err)
+			}
+			msgs := readSysEx(t, daw)
+			if len(msgs) != 1 || !bytes.Equal(msgs[0], tt.want) {
+				t.Fatalf("got % x, want % x", msgs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package lpx
+
+import (
+	"context"
+	"time"
+
+	"github.com/eriner/launchpad"
+)
+
+const (
+	// defaultTimeout is how long requests wait for a response from the
+	// device when no deadline is given.
+	defaultTimeout = 500 * time.Millisecond
+	// pollDelay is how long the reader waits between polls of the inputs.
+	pollDelay = 5 * time.Millisecond
+)
+
+// waiter is a Transact call waiting for a matching SysEx response.
+type waiter struct {
+	match func([]byte) bool
+	ch    chan []byte
+}
+
+// Transact sends a SysEx message over the DAW interface and waits for the
+// first SysEx response for which match returns true, or until ctx is done.
+// Responses are separated from the pad events returned by Listen, so
+// Transact may be used while the device is being listened to.
+func (l *Launchpad) Transact(ctx context.Context, m []byte, match func([]byte) bool) ([]byte, error) {
+	w := &waiter{
+		match: match,
+		ch:    make(chan []byte, 1),
+	}
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, ErrClosed
+	}
+	l.waiters = append(l.waiters, w)
+	l.mu.Unlock()
+	defer l.unwait(w)
+	err := l.write(func() error {
+		return l.DAW.WriteSysEx(m)
+	})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-w.ch:
+		return resp, nil
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, ErrTimeout
+		}
+		return nil, ctx.Err()
+	case <-l.done:
+		return nil, ErrClosed
+	}
+}
+
+// request is Transact with the default timeout
+func (l *Launchpad) request(m []byte, match func([]byte) bool) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return l.Transact(ctx, m, match)
+}
+
+// unwait removes a waiter once its Transact call has returned
+func (l *Launchpad) unwait(w *waiter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.waiters {
+		if l.waiters[i] == w {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// deliver hands a SysEx message to the first waiter it matches. Messages
+// nobody is waiting for are dropped.
+func (l *Launchpad) deliver(m []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, w := range l.waiters {
+		if w.match(m) {
+			w.ch <- m
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// read polls both inputs until the device is closed. Pad events from the
+// MIDI input are sent to Listen, SysEx responses from the DAW input are
+// delivered to Transact calls, and fader movements to FaderEvents.
+//
+// An error reading or writing either input disconnects the device, and
+// read then tries to reconnect it every reconnectDelay.
+func (l *Launchpad) read() {
+	defer l.wg.Done()
+	var lastAttempt time.Time
+	for {
+		select {
+		case <-time.After(pollDelay):
+		case err := <-l.lost:
+			l.disconnect(err)
+		case <-l.done:
+			return
+		}
+		if !l.Connected() {
+			if time.Since(lastAttempt) >= reconnectDelay {
+				lastAttempt = time.Now()
+				l.tryReconnect()
+			}
+			continue
+		}
+		taps, err := l.Read()
+		if err != nil {
+			l.disconnect(err)
+			continue
+		}
+		for _, tap := range taps {
+			select {
+			case l.taps <- tap:
+			default:
+				// nobody is listening, drop the event rather than
+				// holding up SysEx responses
+			}
+		}
+		evts, err := l.DAW.Read()
+		if err != nil {
+			l.disconnect(err)
+			continue
+		}
+		for _, evt := range evts {
+			if evt.SysEx != nil {
+				l.deliver(evt.SysEx)
+				continue
+			}
+			if f, ok := l.fader(evt); ok {
+				select {
+				case l.faderEvents <- f:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// Listen returns launchpad button presses. Every call returns the same channel.
+func (l *Launchpad) Listen() <-chan launchpad.Tap {
+	return l.taps
+}
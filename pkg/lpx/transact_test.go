@@ -0,0 +1,124 @@
+package lpx
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// respond plays the device on the DAW end of a Loopback, answering each
+// SysEx message it reads with the messages returned by answer, until the
+// test ends.
+func respond(t *testing.T, daw *Loopback, answer func(req []byte) [][]byte) {
+	t.Helper()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			evts, err := daw.Read()
+			if err != nil {
+				return
+			}
+			for _, evt := range evts {
+				if evt.SysEx == nil {
+					continue
+				}
+				for _, m := range answer(evt.SysEx) {
+					daw.WriteSysEx(m)
+				}
+			}
+		}
+	}()
+}
+
+func TestTransactMatch(t *testing.T) {
+	lp, _, daw := newTestLaunchpad(t)
+	query := LaunchpadX.msg(FunctionBrightness, nil)
+	respond(t, daw, func(req []byte) [][]byte {
+		if !bytes.Equal(req, query) {
+			return nil
+		}
+		// an unrelated message comes first, and is not returned
+		return [][]byte{
+			LaunchpadX.msg(FunctionLEDSleep, []byte{0x01}),
+			LaunchpadX.msg(FunctionBrightness, []byte{0x40}),
+		}
+	})
+	prefix := append(LaunchpadX.prefix(), byte(FunctionBrightness))
+	resp, err := lp.Transact(context.Background(), query, func(m []byte) bool {
+		return bytes.HasPrefix(m, prefix)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := LaunchpadX.msg(FunctionBrightness, []byte{0x40}); !bytes.Equal(resp, want) {
+		t.Fatalf("got % x, want % x", resp, want)
+	}
+	b, err := lp.CurrentBrightness()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != 0x40 {
+		t.Fatalf("got brightness %#02x, want 0x40", b)
+	}
+}
+
+func TestTransactTimeout(t *testing.T) {
+	lp, _, daw := newTestLaunchpad(t)
+	// the device answers, but never with a matching message
+	respond(t, daw, func(req []byte) [][]byte {
+		return [][]byte{LaunchpadX.msg(FunctionLEDSleep, []byte{0x01})}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := lp.Transact(ctx, LaunchpadX.msg(FunctionBrightness, nil), func(m []byte) bool {
+		return false
+	})
+	if err != ErrTimeout {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("timed out after %v", d)
+	}
+	lp.mu.Lock()
+	waiters := len(lp.waiters)
+	lp.mu.Unlock()
+	if waiters != 0 {
+		t.Fatalf("%d waiters left after timing out", waiters)
+	}
+}
+
+func TestTransactCanceled(t *testing.T) {
+	lp, _, _ := newTestLaunchpad(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := lp.Transact(ctx, LaunchpadX.msg(FunctionBrightness, nil), func(m []byte) bool {
+		return true
+	})
+	if err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestTransactClosed(t *testing.T) {
+	lp, _, _ := newTestLaunchpad(t)
+	lp.Close()
+	_, err := lp.Transact(context.Background(), LaunchpadX.msg(FunctionBrightness, nil), func(m []byte) bool {
+		return true
+	})
+	if err != ErrClosed {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+}
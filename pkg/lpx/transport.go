@@ -0,0 +1,103 @@
+package lpx
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrTransportClosed = errors.New("launchpad: transport is closed")
+)
+
+// Transport carries MIDI messages to and from one port of a device. Each
+// Launchpad X has two ports, MIDI and DAW, and each is opened as its own
+// Transport.
+//
+// lpx does all of its encoding and decoding against Transport, so a
+// Launchpad may be driven by any MIDI library, or by a Loopback in tests.
+type Transport interface {
+	// WriteShort writes a short message, a status byte and two data bytes.
+	WriteShort(status, data1, data2 int64) error
+	// WriteSysEx writes a complete SysEx message, including its 0xf0 and
+	// 0xf7 framing bytes.
+	WriteSysEx(msg []byte) error
+	// Read returns the messages received since the last Read, without
+	// waiting for more. SysEx messages are returned whole.
+	Read() ([]Event, error)
+	Close() error
+}
+
+// Event is a message read from a Transport. SysEx messages, including
+// their framing bytes, are in SysEx. Otherwise Status, Data1 and Data2
+// hold a short message.
+type Event struct {
+	Status int64
+	Data1  int64
+	Data2  int64
+	SysEx  []byte
+}
+
+// Loopback is an in-memory Transport. Messages written to one end of a
+// pair made by NewLoopback are read from the other.
+type Loopback struct {
+	// peer is the other end of the pair, which shares its mutex and closed
+	peer   *Loopback
+	mu     *sync.Mutex
+	closed *bool
+	events []Event
+}
+
+// NewLoopback returns two connected ends of an in-memory Transport. One
+// end is given to a Launchpad, and the other plays the device.
+func NewLoopback() (*Loopback, *Loopback) {
+	mu := &sync.Mutex{}
+	closed := new(bool)
+	a := &Loopback{mu: mu, closed: closed}
+	b := &Loopback{mu: mu, closed: closed, peer: a}
+	a.peer = b
+	return a, b
+}
+
+// WriteShort sends a short message to the other end.
+func (lb *Loopback) WriteShort(status, data1, data2 int64) error {
+	return lb.send(Event{Status: status, Data1: data1, Data2: data2})
+}
+
+// WriteSysEx sends a SysEx message to the other end.
+func (lb *Loopback) WriteSysEx(msg []byte) error {
+	m := make([]byte, len(msg))
+	copy(m, msg)
+	return lb.send(Event{SysEx: m})
+}
+
+func (lb *Loopback) send(e Event) error {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if *lb.closed {
+		return ErrTransportClosed
+	}
+	lb.peer.events = append(lb.peer.events, e)
+	return nil
+}
+
+// Read returns the messages written to the other end since the last Read.
+func (lb *Loopback) Read() ([]Event, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if *lb.closed {
+		return nil, ErrTransportClosed
+	}
+	events := lb.events
+	lb.events = nil
+	return events, nil
+}
+
+// Close closes both ends. Reads and writes on either end fail afterwards,
+// as they would with an unplugged device.
+func (lb *Loopback) Close() error {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	*lb.closed = true
+	return nil
+}
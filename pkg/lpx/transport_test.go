@@ -0,0 +1,47 @@
+package lpx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoopback(t *testing.T) {
+	host, dev := NewLoopback()
+	if err := host.WriteShort(0x90, 11, 100); err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte{0xf0, 0x7e, 0x7f, 0x06, 0x01, 0xf7}
+	if err := host.WriteSysEx(msg); err != nil {
+		t.Fatal(err)
+	}
+	// the message is copied, so the writer may reuse it
+	msg[1] = 0x00
+	want := []Event{
+		{Status: 0x90, Data1: 11, Data2: 100},
+		{SysEx: []byte{0xf0, 0x7e, 0x7f, 0x06, 0x01, 0xf7}},
+	}
+	got, err := dev.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	// messages are only read once, and not by the end which wrote them
+	if got, err := dev.Read(); err != nil || got != nil {
+		t.Fatalf("got %+v, %v on a second read", got, err)
+	}
+	if got, err := host.Read(); err != nil || got != nil {
+		t.Fatalf("got %+v, %v on the writing end", got, err)
+	}
+
+	if err := dev.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := host.WriteShort(0x90, 11, 100); err != ErrTransportClosed {
+		t.Fatalf("write after close: got %v, want ErrTransportClosed", err)
+	}
+	if _, err := host.Read(); err != ErrTransportClosed {
+		t.Fatalf("read after close: got %v, want ErrTransportClosed", err)
+	}
+}
@@ -5,20 +5,22 @@ import (
 	"time"
 
 	"github.com/eriner/launchpad"
+	"github.com/eriner/launchpad/pkg/color"
 )
 
-// SimulatedFeedback lights the buttons with RGB values for a duration
-func SimulatedFeedback(next launchpad.HitHandler, r, g, b int8, t time.Duration) launchpad.HitHandler {
+// SimulatedFeedback lights the buttons with a color for a duration
+func SimulatedFeedback(next launchpad.HitHandler, rgb color.Color, t time.Duration) launchpad.HitHandler {
 	return launchpad.HitFunc(func(p *launchpad.Pad) error {
 		// save current state
-		cr := p.Light.R
-		cg := p.Light.G
-		cb := p.Light.B
-		p.Light.RGB(r, g, b)
-		time.Sleep(t)
-		p.Light.RGB(cr, cg, cb)
-		next.Apply(p)
-		return nil
+		c := p.Light()
+		p.UpdateLight(func(l *launchpad.Light) {
+			l.SetRGB(rgb)
+		})
+		wait(p, t)
+		p.UpdateLight(func(l *launchpad.Light) {
+			l.SetRGB(c.RGBColor())
+		})
+		return next.Apply(p)
 	})
 }
 
@@ -26,27 +28,57 @@ func SimulatedFeedback(next launchpad.HitHandler, r, g, b int8, t time.Duration)
 func SimulatedFeedbackInverted(next launchpad.HitHandler, t time.Duration) launchpad.HitHandler {
 	return launchpad.HitFunc(func(p *launchpad.Pad) error {
 		// save current state
-		cr := p.Light.R
-		cg := p.Light.G
-		cb := p.Light.B
-		p.Light.RGB(127-cr, 127-cg, 127-cb)
-		time.Sleep(t)
-		p.Light.RGB(cr, cg, cb)
-		next.Apply(p)
-		return nil
+		c := p.Light()
+		p.UpdateLight(func(l *launchpad.Light) {
+			l.SetRGB(c.RGBColor().Invert())
+		})
+		wait(p, t)
+		p.UpdateLight(func(l *launchpad.Light) {
+			l.SetRGB(c.RGBColor())
+		})
+		return next.Apply(p)
 	})
 }
 
 // SimulatedFeedbackPulseToggle causes the lights to pulse when pressed
 func SimulatedFeedbackPulseToggle(next launchpad.HitHandler) launchpad.HitHandler {
 	return launchpad.HitFunc(func(p *launchpad.Pad) error {
-		switch p.Light.Effect {
-		case launchpad.EffectStatic:
-			p.Light.Effect = launchpad.EffectPulse
-		case launchpad.EffectPulse:
-			p.Light.Effect = launchpad.EffectStatic
+		p.UpdateLight(func(l *launchpad.Light) {
+			switch l.Effect {
+			case launchpad.EffectStatic:
+				l.Effect = launchpad.EffectPulse
+			case launchpad.EffectPulse:
+				l.Effect = launchpad.EffectStatic
+			}
+		})
+		return next.Apply(p)
+	})
+}
+
+// FlashOnError lights the button red for a duration when the wrapped
+// handler returns an error. The error is still returned.
+func FlashOnError(next launchpad.HitHandler, t time.Duration) launchpad.HitHandler {
+	return launchpad.HitFunc(func(p *launchpad.Pad) error {
+		err := next.Apply(p)
+		if err == nil {
+			return nil
 		}
-		next.Apply(p)
-		return nil
+		// save current state
+		c := p.Light()
+		p.UpdateLight(func(l *launchpad.Light) {
+			l.Static()
+			l.SetRGB(color.Red)
+		})
+		wait(p, t)
+		p.SetLight(c)
+		return err
 	})
 }
+
+// wait sleeps for t, or until the pad's grid is closed
+func wait(p *launchpad.Pad, t time.Duration) {
+	select {
+	case <-time.After(t):
+	case <-p.Done():
+	}
+}
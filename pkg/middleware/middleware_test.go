@@ -0,0 +1,70 @@
+package middleware_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eriner/launchpad"
+	"github.com/eriner/launchpad/pkg/color"
+	"github.com/eriner/launchpad/pkg/middleware"
+)
+
+func TestFlashOnError(t *testing.T) {
+	failed := errors.New("handler failed")
+	const flash = 50 * time.Millisecond
+	tests := []struct {
+		name  string
+		err   error
+		flash bool
+	}{
+		{"error", failed, true},
+		{"no error", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pad := launchpad.NewPad()
+			before := launchpad.Light{Effect: launchpad.EffectPulse, Color: 21}
+			pad.SetLight(before)
+			ran := make(chan struct{})
+			h := middleware.FlashOnError(launchpad.HitFunc(func(p *launchpad.Pad) error {
+				close(ran)
+				return tt.err
+			}), flash)
+			done := make(chan error, 1)
+			start := time.Now()
+			go func() {
+				done <- h.Apply(pad)
+			}()
+			<-ran
+			if tt.flash {
+				// the pad is lit red while the handler waits
+				deadline := time.Now().Add(flash / 2)
+				for {
+					l := pad.Light()
+					if l.Effect == launchpad.EffectStatic && l.RGBColor() == color.Red {
+						break
+					}
+					if time.Now().After(deadline) {
+						t.Fatalf("pad is %+v during the flash, want static red", l)
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}
+			select {
+			case err := <-done:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %v, want %v", err, tt.err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("handler did not return")
+			}
+			if d := time.Since(start); tt.flash && d < flash {
+				t.Fatalf("returned after %v, want at least %v", d, flash)
+			}
+			if l := pad.Light(); l != before {
+				t.Fatalf("pad is %+v afterwards, want %+v", l, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package launchpad
+
+import (
+	"context"
+	"time"
+
+	"github.com/eriner/launchpad/pkg/color"
+)
+
+// ScrollText scrolls text from right to left across the 8x8 pads of the
+// grid with a built-in bitmap font, moving one column every interval.
+// Unlike a device's own text scrolling, this works on any device and
+// composes with other lights: lit pixels are drawn in c over the pads'
+// lights without changing them, so lights set while the text scrolls show
+// once it has passed. Pads with DisplayLocked lights are not drawn over.
+//
+// ScrollText blocks until the text has scrolled off the grid, ctx is done
+// or the grid is closed. If loop is true, the text repeats until ctx is done.
+// Only one text may scroll across a grid at a time.
+func (g *Grid) ScrollText(ctx context.Context, text string, c color.Color, interval time.Duration, loop bool) error {
+	var cols []byte
+	for _, r := range text {
+		gl := glyph(r)
+		cols = append(cols, gl[:]...)
+		// a column of space between glyphs
+		cols = append(cols, 0)
+	}
+	defer g.setOverlay(nil)
+	// the text starts just off the right edge of the grid
+	offset := -8
+	for {
+		overlay := make(map[Coordinate]Light)
+		for x := 1; x < 9; x++ {
+			var col byte
+			if i := offset + x - 1; i >= 0 && i < len(cols) {
+				col = cols[i]
+			}
+			// rows of the font are drawn from the top of the grid down
+			for row := 0; row < 7; row++ {
+				if col>>row&1 == 0 {
+					continue
+				}
+				coord := Coord(x, 8-row)
+				light := Light{Coord: coord, Effect: EffectStatic}
+				light.SetRGB(c)
+				overlay[coord] = light
+			}
+		}
+		g.setOverlay(overlay)
+		offset++
+		if offset > len(cols) {
+			if !loop {
+				return nil
+			}
+			offset = -8
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-g.done:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package launchpad_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eriner/launchpad"
+	"github.com/eriner/launchpad/pkg/color"
+	"github.com/eriner/launchpad/pkg/fake"
+)
+
+// waitLit waits for the device to light the pad at c so that ok returns
+// true, failing the test if it doesn't within a second.
+func waitLit(t *testing.T, dev *fake.Launchpad, c launchpad.Coordinate, ok func(launchpad.Light) bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if lit, _ := dev.Lit(c); ok(lit) {
+			return
+		}
+		if time.Now().After(deadline) {
+			lit, _ := dev.Lit(c)
+			t.Fatalf("%v is lit %+v", c, lit)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGridScrollText(t *testing.T) {
+	g, dev := newTestGrid(t)
+	text := color.RGB(0, 127, 0)
+	isText := func(l launchpad.Light) bool {
+		return l.RGBColor() == text
+	}
+	// the '|' glyph is a full column, drawn from Y 8 down to Y 2
+	done := make(chan error, 1)
+	go func() {
+		done <- g.ScrollText(context.Background(), "|", text, 20*time.Millisecond, false)
+	}()
+	c := launchpad.Coord(4, 5)
+	waitLit(t, dev, c, isText)
+	// the text is drawn over the pads without changing their lights
+	if light := g.Pad(4, 5).Light(); isText(light) {
+		t.Fatalf("pad under the text is %+v", light)
+	}
+	// a light set under the text shows once the text has passed
+	g.Pad(4, 5).UpdateLight(func(l *launchpad.Light) {
+		l.RGB(127, 0, 0)
+	})
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ScrollText did not return")
+	}
+	waitLit(t, dev, c, func(l launchpad.Light) bool {
+		return l.R == 127 && l.G == 0
+	})
+	for x := 1; x < 9; x++ {
+		for y := 2; y < 9; y++ {
+			waitLit(t, dev, launchpad.Coord(x, y), func(l launchpad.Light) bool {
+				return !isText(l)
+			})
+		}
+	}
+}
+
+func TestGridScrollTextContext(t *testing.T) {
+	g, dev := newTestGrid(t)
+	text := color.RGB(0, 0, 127)
+	g.Pad(1, 8).SetLight(launchpad.Light{
+		Coord:         launchpad.Coord(1, 8),
+		Effect:        launchpad.EffectStatic,
+		R:             127,
+		DisplayLocked: true,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := g.ScrollText(ctx, "||||", text, time.Millisecond, true)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	for x := 1; x < 9; x++ {
+		for y := 2; y < 9; y++ {
+			waitLit(t, dev, launchpad.Coord(x, y), func(l launchpad.Light) bool {
+				return l.RGBColor() != text
+			})
+		}
+	}
+	// display locked pads are not drawn over
+	for _, f := range dev.Frames() {
+		for _, l := range f.Lights {
+			if l.Coord == launchpad.Coord(1, 8) && l.RGBColor() == text {
+				t.Fatalf("display locked pad was drawn %+v", l)
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package launchpad
+
+import "math"
+
+// VelocityCurve maps the velocity a pad was pressed with, 1-127, to the
+// velocity reported by the grid. Results are clamped to 1-127.
+type VelocityCurve func(velocity int) int
+
+// VelocityGamma returns a curve which raises velocities to the power of
+// gamma. A gamma below 1 makes soft presses louder, above 1 makes them
+// quieter. 1 is linear.
+func VelocityGamma(gamma float64) VelocityCurve {
+	return func(v int) int {
+		return int(math.Round(127 * math.Pow(float64(v)/127, gamma)))
+	}
+}
+
+// VelocityFixed returns a curve which reports every press at velocity v,
+// like the device's fixed velocity setting.
+func VelocityFixed(v int) VelocityCurve {
+	return func(int) int {
+		return v
+	}
+}
+
+// VelocityRange returns a curve which scales velocities linearly into the
+// range lo to hi.
+func VelocityRange(lo, hi int) VelocityCurve {
+	return func(v int) int {
+		return lo + (v-1)*(hi-lo)/126
+	}
+}
@@ -0,0 +1,68 @@
+package launchpad_test
+
+import (
+	"testing"
+
+	"github.com/eriner/launchpad"
+)
+
+func TestVelocityCurves(t *testing.T) {
+	tests := []struct {
+		name  string
+		curve launchpad.VelocityCurve
+		in    []int
+		want  []int
+	}{
+		{"linear gamma", launchpad.VelocityGamma(1), []int{1, 64, 127}, []int{1, 64, 127}},
+		{"soft gamma", launchpad.VelocityGamma(0.5), []int{1, 32, 127}, []int{11, 64, 127}},
+		{"hard gamma", launchpad.VelocityGamma(2), []int{1, 64, 127}, []int{0, 32, 127}},
+		{"fixed", launchpad.VelocityFixed(100), []int{1, 64, 127}, []int{100, 100, 100}},
+		{"range", launchpad.VelocityRange(40, 100), []int{1, 64, 127}, []int{40, 70, 100}},
+		{"inverted range", launchpad.VelocityRange(127, 1), []int{1, 64, 127}, []int{127, 64, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, v := range tt.in {
+				if got := tt.curve(v); got != tt.want[i] {
+					t.Errorf("curve(%d) = %d, want %d", v, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGridVelocityCurve(t *testing.T) {
+	tests := []struct {
+		name  string
+		curve launchpad.VelocityCurve
+		in    int
+		want  int
+	}{
+		{"none", nil, 90, 90},
+		{"range", launchpad.VelocityRange(1, 64), 127, 64},
+		{"fixed", launchpad.VelocityFixed(100), 20, 100},
+		// presses always keep a velocity, so results are clamped to 1-127
+		{"below min", launchpad.VelocityGamma(2), 1, 1},
+		{"fixed below min", launchpad.VelocityFixed(-5), 64, 1},
+		{"fixed above max", launchpad.VelocityFixed(200), 64, 127},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, dev := newTestGrid(t)
+			g.SetVelocityCurve(tt.curve)
+			presses := g.Presses()
+			dev.PressVelocity(6, 6, tt.in)
+			if tap := nextTap(t, presses); tap.Velocity != tt.want {
+				t.Fatalf("press reported velocity %d, want %d", tap.Velocity, tt.want)
+			}
+			if v := g.Pad(6, 6).Velocity(); v != tt.want {
+				t.Fatalf("pad velocity is %d, want %d", v, tt.want)
+			}
+			// releases carry no velocity to reshape
+			dev.Release(6, 6)
+			if tap := nextTap(t, presses); tap.Type != launchpad.Release || tap.Velocity != 0 {
+				t.Fatalf("got %s with velocity %d, want a release without one", tap.Type, tap.Velocity)
+			}
+		})
+	}
+}